@@ -0,0 +1,287 @@
+// Package account implements the account structure of BIP 44, 49, 84 and 86:
+// m / purpose' / coin_type' / account' / change / address_index.
+// It hands out receive and change addresses and discovers used addresses with a gap limit.
+//
+// Spec: https://github.com/bitcoin/bips/blob/master/bip-0044.mediawiki
+package account
+
+import (
+	"errors"
+	"strings"
+
+	bip32 "github.com/koba-e964/bip32-typesafe"
+	"github.com/koba-e964/bip32-typesafe/descriptor"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+)
+
+var (
+	ErrorInvalidPurpose    = errors.New("purpose is not one of 44, 49, 84 and 86")
+	ErrorNotAccountKey     = errors.New("key is not at the account level (depth 3)")
+	ErrorGapLimitExceeded  = errors.New("too many unused receive addresses")
+	ErrorIndexOutOfRange   = errors.New("address index is out of the non-hardened range")
+	ErrorInvalidChain      = errors.New("chain is neither External nor Change")
+	ErrorInvalidGapLimit   = errors.New("gap limit must be positive")
+	ErrorHardenedIndexUsed = errors.New("coin type and account index must be non-hardened indices; they are hardened in the path")
+)
+
+// DefaultGapLimit is the address gap limit recommended in BIP 44.
+const DefaultGapLimit = 20
+
+// Purpose is the first level of the path, which determines the script type of addresses.
+type Purpose uint32
+
+const (
+	BIP44 Purpose = 44 // P2PKH
+	BIP49 Purpose = 49 // P2WPKH nested in P2SH
+	BIP84 Purpose = 84 // P2WPKH
+	BIP86 Purpose = 86 // P2TR with the key path only
+)
+
+// Chain is the fourth level of the path.
+type Chain uint32
+
+const (
+	External Chain = 0 // receive addresses
+	Change   Chain = 1 // change addresses
+)
+
+// Coin types of SLIP 44.
+const (
+	CoinTypeBitcoin uint32 = 0
+	CoinTypeTestnet uint32 = 1
+)
+
+// UsedChecker tells if an address has been used, typically by looking up its transaction history.
+type UsedChecker interface {
+	IsUsed(address *Address) (bool, error)
+}
+
+// Address is an address of an account.
+type Address struct {
+	Chain        Chain
+	Index        uint32
+	PublicKey    secp256k1.Compressed
+	Origin       *bip32.KeyOrigin // nil if the origin of the account key is unknown
+	ScriptPubKey []byte
+	address      string
+}
+
+// String returns the encoded address.
+func (a *Address) String() string {
+	return a.address
+}
+
+// Account is an account of BIP 44, 49, 84 or 86. It remembers which addresses are used and which are handed out.
+// An Account is not safe for concurrent use.
+type Account struct {
+	purpose     Purpose
+	network     descriptor.Network
+	key         *bip32.PublicKey
+	descriptors [2]*descriptor.Descriptor
+	gapLimit    uint32
+	// the number of addresses handed out or known to be used, per chain
+	next [2]uint32
+	// 1 + the largest used index, per chain
+	used [2]uint32
+}
+
+// NewFromMaster derives the account m/purpose'/coinType'/index' from master.
+func NewFromMaster(master *bip32.PrivateKey, purpose Purpose, coinType uint32, index uint32, network descriptor.Network) (*Account, error) {
+	if index >= bip32.FirstHardenedChildIndex || coinType >= bip32.FirstHardenedChildIndex {
+		return nil, ErrorHardenedIndexUsed
+	}
+	key := master
+	for _, childIdx := range []uint32{uint32(purpose), coinType, index} {
+		var err error
+		key, err = key.NewChildKey(bip32.FirstHardenedChildIndex + childIdx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewFromPublicKey(key.GetPublicKey(), purpose, network)
+}
+
+// NewFromPublicKey makes a watch-only Account from an account-level key (depth 3).
+// If the origin of key is known, addresses have their origins.
+func NewFromPublicKey(key *bip32.PublicKey, purpose Purpose, network descriptor.Network) (*Account, error) {
+	if key.Depth() != 3 {
+		return nil, ErrorNotAccountKey
+	}
+	wrapper, ok := map[Purpose]string{
+		BIP44: "pkh(%)",
+		BIP49: "sh(wpkh(%))",
+		BIP84: "wpkh(%)",
+		BIP86: "tr(%)",
+	}[purpose]
+	if !ok {
+		return nil, ErrorInvalidPurpose
+	}
+	a := Account{purpose: purpose, network: network, key: key, gapLimit: DefaultGapLimit}
+	keyExpression := key.B58Serialize()
+	if origin := key.KeyOrigin(); origin != nil {
+		keyExpression = "[" + origin.String() + "]" + keyExpression
+	}
+	for _, chain := range []Chain{External, Change} {
+		path := "/0/*"
+		if chain == Change {
+			path = "/1/*"
+		}
+		d, err := descriptor.Parse(strings.Replace(wrapper, "%", keyExpression+path, 1))
+		if err != nil {
+			return nil, err
+		}
+		a.descriptors[chain] = d
+	}
+	return &a, nil
+}
+
+// Purpose returns the purpose of this Account.
+func (a *Account) Purpose() Purpose {
+	return a.purpose
+}
+
+// PublicKey returns the account-level extended public key.
+func (a *Account) PublicKey() *bip32.PublicKey {
+	return a.key
+}
+
+// Descriptor returns the output script descriptor of chain, such as wpkh([73c5da0a/84'/0'/0']xpub.../0/*).
+func (a *Account) Descriptor(chain Chain) (*descriptor.Descriptor, error) {
+	if chain != External && chain != Change {
+		return nil, ErrorInvalidChain
+	}
+	return a.descriptors[chain], nil
+}
+
+// GapLimit returns the gap limit, which is DefaultGapLimit unless set by SetGapLimit.
+func (a *Account) GapLimit() uint32 {
+	return a.gapLimit
+}
+
+// SetGapLimit sets the gap limit.
+func (a *Account) SetGapLimit(gapLimit uint32) error {
+	if gapLimit == 0 {
+		return ErrorInvalidGapLimit
+	}
+	a.gapLimit = gapLimit
+	return nil
+}
+
+// Address returns the address at index of chain.
+func (a *Account) Address(chain Chain, index uint32) (*Address, error) {
+	d, err := a.Descriptor(chain)
+	if err != nil {
+		return nil, err
+	}
+	if index >= bip32.FirstHardenedChildIndex {
+		return nil, ErrorIndexOutOfRange
+	}
+	key, err := d.Keys()[0].ExtendedPublicKey(index)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := d.Expand(index)
+	if err != nil {
+		return nil, err
+	}
+	address, err := descriptor.Address(outputs[0].ScriptPubKey, a.network)
+	if err != nil {
+		return nil, err
+	}
+	return &Address{
+		Chain:        chain,
+		Index:        index,
+		PublicKey:    key.PublicKey(),
+		Origin:       key.KeyOrigin(),
+		ScriptPubKey: outputs[0].ScriptPubKey,
+		address:      address,
+	}, nil
+}
+
+// NextReceiveAddress hands out the first receive address that is neither used nor handed out.
+// ErrorGapLimitExceeded is returned if it would be more than GapLimit addresses after the last used one,
+// because such addresses are not found by discovery (BIP 44).
+func (a *Account) NextReceiveAddress() (*Address, error) {
+	if a.next[External]-a.used[External] >= a.gapLimit {
+		return nil, ErrorGapLimitExceeded
+	}
+	return a.handOut(External)
+}
+
+// NextChangeAddress hands out the first change address that is neither used nor handed out.
+// Change addresses are not limited by the gap limit, since the wallet uses them itself.
+func (a *Account) NextChangeAddress() (*Address, error) {
+	return a.handOut(Change)
+}
+
+func (a *Account) handOut(chain Chain) (*Address, error) {
+	address, err := a.Address(chain, a.next[chain])
+	if err != nil {
+		return nil, err
+	}
+	a.next[chain]++
+	return address, nil
+}
+
+// MarkUsed records that the address at index of chain is used.
+func (a *Account) MarkUsed(chain Chain, index uint32) error {
+	if chain != External && chain != Change {
+		return ErrorInvalidChain
+	}
+	if index >= bip32.FirstHardenedChildIndex {
+		return ErrorIndexOutOfRange
+	}
+	a.used[chain] = max(a.used[chain], index+1)
+	a.next[chain] = max(a.next[chain], index+1)
+	return nil
+}
+
+// Discover scans both chains with checker until GapLimit consecutive addresses are unused, and marks the used ones.
+// It returns true if any address is used.
+func (a *Account) Discover(checker UsedChecker) (bool, error) {
+	found := false
+	for _, chain := range []Chain{External, Change} {
+		unused := uint32(0)
+		for index := uint32(0); unused < a.gapLimit && index < bip32.FirstHardenedChildIndex; index++ {
+			address, err := a.Address(chain, index)
+			if err != nil {
+				return false, err
+			}
+			used, err := checker.IsUsed(address)
+			if err != nil {
+				return false, err
+			}
+			if !used {
+				unused++
+				continue
+			}
+			unused = 0
+			found = true
+			if err := a.MarkUsed(chain, index); err != nil {
+				return false, err
+			}
+		}
+	}
+	return found, nil
+}
+
+// DiscoverAccounts runs account discovery (BIP 44): accounts 0, 1, ... are discovered in order,
+// and discovery stops at the first account without used addresses. The used accounts are returned.
+func DiscoverAccounts(master *bip32.PrivateKey, purpose Purpose, coinType uint32, network descriptor.Network, checker UsedChecker) ([]*Account, error) {
+	var result []*Account
+	for index := uint32(0); index < bip32.FirstHardenedChildIndex; index++ {
+		a, err := NewFromMaster(master, purpose, coinType, index, network)
+		if err != nil {
+			return nil, err
+		}
+		found, err := a.Discover(checker)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			break
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
@@ -0,0 +1,151 @@
+package account
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	bip32 "github.com/koba-e964/bip32-typesafe"
+	"github.com/koba-e964/bip32-typesafe/descriptor"
+	"github.com/stretchr/testify/assert"
+)
+
+// the master key of "abandon abandon ... about", used in the test vectors of BIP 84 and 86
+func testMaster() *bip32.PrivateKey {
+	seed, _ := hex.DecodeString("5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4")
+	return bip32.NewMasterKey(seed)
+}
+
+// fakeChecker is a UsedChecker with a fixed set of used addresses.
+type fakeChecker struct {
+	used    map[string]bool
+	checked int
+}
+
+func (c *fakeChecker) IsUsed(address *Address) (bool, error) {
+	c.checked++
+	return c.used[address.String()], nil
+}
+
+type failingChecker struct{}
+
+var errorBackend = errors.New("backend is down")
+
+func (failingChecker) IsUsed(address *Address) (bool, error) {
+	return false, errorBackend
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		purpose Purpose
+		chain   Chain
+		index   uint32
+		address string
+	}{
+		{purpose: BIP44, chain: External, index: 0, address: "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		{purpose: BIP49, chain: External, index: 0, address: "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf"},
+		// https://github.com/bitcoin/bips/blob/master/bip-0084.mediawiki#test-vectors
+		{purpose: BIP84, chain: External, index: 0, address: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{purpose: BIP84, chain: External, index: 1, address: "bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"},
+		{purpose: BIP84, chain: Change, index: 0, address: "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"},
+		// https://github.com/bitcoin/bips/blob/master/bip-0086.mediawiki#test-vectors
+		{purpose: BIP86, chain: External, index: 0, address: "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+		{purpose: BIP86, chain: External, index: 1, address: "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh"},
+		{purpose: BIP86, chain: Change, index: 0, address: "bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7"},
+	}
+	master := testMaster()
+	for _, test := range tests {
+		a, err := NewFromMaster(master, test.purpose, CoinTypeBitcoin, 0, descriptor.Mainnet)
+		assert.Nil(t, err)
+		address, err := a.Address(test.chain, test.index)
+		assert.Nil(t, err)
+		assert.Equal(t, test.address, address.String())
+		assert.Equal(t, test.chain, address.Chain)
+		assert.Equal(t, test.index, address.Index)
+
+		// the watch-only account from the account-level key yields the same addresses
+		watchOnly, err := NewFromPublicKey(a.PublicKey(), test.purpose, descriptor.Mainnet)
+		assert.Nil(t, err)
+		same, err := watchOnly.Address(test.chain, test.index)
+		assert.Nil(t, err)
+		assert.Equal(t, address, same)
+	}
+	a, err := NewFromMaster(master, BIP84, CoinTypeBitcoin, 0, descriptor.Mainnet)
+	assert.Nil(t, err)
+	address, err := a.Address(Change, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, "73c5da0a/84'/0'/0'/1/3", address.Origin.String())
+	_, err = a.Address(Chain(2), 0)
+	assert.Equal(t, ErrorInvalidChain, err)
+	_, err = a.Address(External, bip32.FirstHardenedChildIndex)
+	assert.Equal(t, ErrorIndexOutOfRange, err)
+
+	_, err = NewFromMaster(master, Purpose(45), CoinTypeBitcoin, 0, descriptor.Mainnet)
+	assert.Equal(t, ErrorInvalidPurpose, err)
+	_, err = NewFromPublicKey(master.GetPublicKey(), BIP84, descriptor.Mainnet)
+	assert.Equal(t, ErrorNotAccountKey, err)
+}
+
+func TestNextAddress(t *testing.T) {
+	a, err := NewFromMaster(testMaster(), BIP84, CoinTypeBitcoin, 0, descriptor.Mainnet)
+	assert.Nil(t, err)
+	assert.Nil(t, a.SetGapLimit(3))
+	for i := uint32(0); i < 3; i++ {
+		address, err := a.NextReceiveAddress()
+		assert.Nil(t, err)
+		assert.Equal(t, i, address.Index)
+	}
+	_, err = a.NextReceiveAddress()
+	assert.Equal(t, ErrorGapLimitExceeded, err)
+	// once an address is used, addresses after it can be handed out
+	assert.Nil(t, a.MarkUsed(External, 1))
+	address, err := a.NextReceiveAddress()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), address.Index)
+
+	for i := uint32(0); i < 5; i++ {
+		address, err := a.NextChangeAddress()
+		assert.Nil(t, err)
+		assert.Equal(t, Change, address.Chain)
+		assert.Equal(t, i, address.Index)
+	}
+	assert.Equal(t, ErrorInvalidGapLimit, a.SetGapLimit(0))
+}
+
+func TestDiscover(t *testing.T) {
+	master := testMaster()
+	account0, err := NewFromMaster(master, BIP84, CoinTypeBitcoin, 0, descriptor.Mainnet)
+	assert.Nil(t, err)
+	account1, err := NewFromMaster(master, BIP84, CoinTypeBitcoin, 1, descriptor.Mainnet)
+	assert.Nil(t, err)
+	checker := &fakeChecker{used: map[string]bool{}}
+	markUsed := func(a *Account, chain Chain, index uint32) {
+		address, err := a.Address(chain, index)
+		assert.Nil(t, err)
+		checker.used[address.String()] = true
+	}
+	// index 25 is within the gap limit after 5, but index 50 is not
+	markUsed(account0, External, 5)
+	markUsed(account0, External, 25)
+	markUsed(account0, External, 50)
+	markUsed(account0, Change, 2)
+	markUsed(account1, Change, 0)
+
+	accounts, err := DiscoverAccounts(master, BIP84, CoinTypeBitcoin, descriptor.Mainnet, checker)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(accounts))
+	address, err := accounts[0].NextReceiveAddress()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(26), address.Index)
+	address, err = accounts[0].NextChangeAddress()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), address.Index)
+	address, err = accounts[1].NextReceiveAddress()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), address.Index)
+	// account 0: 46 + 23 addresses, account 1: 20 + 21 addresses, account 2: 20 + 20 addresses
+	assert.Equal(t, 46+23+20+21+20+20, checker.checked)
+
+	_, err = account0.Discover(failingChecker{})
+	assert.Equal(t, errorBackend, err)
+}
@@ -7,6 +7,9 @@ import (
 	"crypto/hmac"
 	"crypto/sha512"
 	"errors"
+	"fmt"
+
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
 )
 
 var (
@@ -17,11 +20,85 @@ var (
 	ErrorInvalidPublicKey                     = errors.New("public key is invalid")
 	ErrorInvalidPrivateKey                    = errors.New("private key is invalid")
 	ErrorChecksumMismatch                     = errors.New("checksum mismatch")
+	ErrorInvalidEncoding                      = errors.New("invalid base58 encoding")
 	ErrorZeroDepthAndNonZeroParentFingerprint = errors.New("zero depth with non-zero parent fingerprint")
 	ErrorZeroDepthAndNonZeroIndex             = errors.New("zero depth with non-zero index")
 	ErrorPrivateKeyNotInRange                 = errors.New("private key not in range (1 <= p <= n-1)")
+	ErrorHardenedChildKey                     = errors.New("can't recover a parent key from a hardened child key")
+	ErrorParentMismatch                       = errors.New("the child key is not a child of the parent key")
+	ErrorKeyOriginMismatch                    = errors.New("key origin is inconsistent with the key")
+	ErrorInvalidWIF                           = errors.New("WIF is malformed")
+	ErrorInvalidBIP38                         = errors.New("BIP 38 encrypted key is malformed")
+	ErrorInvalidIntermediateCode              = errors.New("BIP 38 intermediate code is malformed")
+	ErrorInvalidConfirmationCode              = errors.New("BIP 38 confirmation code is malformed")
+	ErrorPassphraseMismatch                   = errors.New("passphrase is wrong (address hash mismatch)")
+	ErrorLotSequenceOutOfRange                = errors.New("lot must be < 2^20 and sequence must be < 2^12")
+	ErrorInvalidEncryptionParams              = errors.New("encryption parameters are invalid or too expensive")
+	ErrorInvalidEncryptedKey                  = errors.New("encrypted key is malformed")
+	ErrorUnsupportedEncryptedKeyVersion       = errors.New("format version of encrypted key is not supported")
+	ErrorDecryptionFailed                     = errors.New("wrong passphrase or corrupted encrypted key")
+	ErrorUnsupportedScanType                  = errors.New("key can only be scanned from string or []byte")
+	ErrorInvalidEthereumAddress               = errors.New("invalid Ethereum address (must be 0x followed by 40 hexadecimal digits)")
+	ErrorInvalidCacheCapacity                 = errors.New("capacity of DerivationCache must be positive")
+)
+
+// DerivationError is returned when a child key cannot be derived.
+// It matches its Reason, one of the sentinel errors above, with errors.Is.
+type DerivationError struct {
+	Depth  byte   // the depth of the parent key
+	Index  uint32 // the child index
+	Reason error
+}
+
+func (e *DerivationError) Error() string {
+	return fmt.Sprintf("deriving child %s of a key at depth %d: %v", formatChildIndex(e.Index), e.Depth, e.Reason)
+}
+
+func (e *DerivationError) Unwrap() error {
+	return e.Reason
+}
+
+// DecodeError is returned when a serialized extended key is malformed.
+// It matches its Reason, one of the sentinel errors above, with errors.Is.
+// It doesn't contain the key itself, so it can be logged even if the key is private.
+type DecodeError struct {
+	Field  string // "length", "encoding", "version", "parent fingerprint", "child number", "key data" or "checksum"
+	Offset int    // the offset of Field in the serialized key (in bytes), or 0 if Field is "length" or "encoding"
+	Reason error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decoding extended key: %s at offset %d: %v", e.Field, e.Offset, e.Reason)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Reason
+}
+
+// fields of serialized extended keys, used in DecodeError
+const (
+	fieldLength            = "length"
+	fieldEncoding          = "encoding" // base58 encoding; characters outside the alphabet
+	fieldVersion           = "version"
+	fieldParentFingerprint = "parent fingerprint"
+	fieldChildNumber       = "child number"
+	fieldKeyData           = "key data"
+	fieldChecksum          = "checksum"
 )
 
+func newDecodeError(field string, reason error) *DecodeError {
+	offsets := map[string]int{
+		fieldLength:            0,
+		fieldEncoding:          0,
+		fieldVersion:           0,
+		fieldParentFingerprint: 5,
+		fieldChildNumber:       9,
+		fieldKeyData:           45,
+		fieldChecksum:          78,
+	}
+	return &DecodeError{Field: field, Offset: offsets[field], Reason: reason}
+}
+
 // NewMasterKey generates a new master private key with the given seed.
 //
 // Example:
@@ -42,7 +119,8 @@ func NewMasterKey(seed []byte) *PrivateKey {
 		parentFingerprint: [4]byte{},
 		childNumber:       [4]byte{},
 		chainCode:         lr,
-		privateKey:        ll,
+		privateKey:        secp256k1.NewSecretScalar(ll),
+		public:            &publicPart{},
 	}
 	return &master
 }
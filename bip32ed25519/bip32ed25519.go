@@ -0,0 +1,107 @@
+// Package bip32ed25519 provides BIP32-Ed25519 (hierarchical deterministic keys over ed25519) related functions.
+//
+// Unlike SLIP-0010, BIP32-Ed25519 allows for public parent key -> public child key derivation.
+// This is the derivation scheme used by Cardano wallets (derivation scheme V2, Icarus master keys).
+//
+// Spec: https://input-output-hk.github.io/adrestia/static/Ed25519_BIP.pdf
+//
+// Master key generation: https://github.com/cardano-foundation/CIPs/blob/master/CIP-0003/Icarus.md
+package bip32ed25519
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+
+	"filippo.io/edwards25519"
+	bip32 "github.com/koba-e964/bip32-typesafe"
+)
+
+// FirstHardenedChildIndex is the first index of hardened child keys.
+// It is the same value as in BIP 32.
+const FirstHardenedChildIndex uint32 = bip32.FirstHardenedChildIndex
+
+const (
+	PrivateKeyLengthInBytes = 96 // when serialized, private keys have this length (k_L || k_R || chain code)
+	PublicKeyLengthInBytes  = 64 // when serialized, public keys have this length (A || chain code)
+)
+
+var (
+	ErrorHardenedPublicChildKey = errors.New("can't create a hardened child key from a public key")
+	ErrorInvalidPublicKey       = errors.New("public key is invalid")
+	ErrorInvalidPrivateKey      = errors.New("private key is invalid")
+)
+
+// NewMasterKeyIcarus generates a new master private key from BIP 39 entropy (not the mnemonic nor the BIP 39 seed) and a passphrase,
+// as Icarus-style Cardano wallets do.
+//
+// Example:
+//
+//	// entropy of the mnemonic "eight country switch draw meat scout mystery blade tip drift useless good keep usage title"
+//	entropy, err := hex.DecodeString("46e62370a138a182a498b8e2885bc032379ddf38")
+//	master := NewMasterKeyIcarus(entropy, nil)
+func NewMasterKeyIcarus(entropy []byte, passphrase []byte) *PrivateKey {
+	// The only possible error is about the key length, which is valid here.
+	data, _ := pbkdf2.Key(sha512.New, string(passphrase), entropy, 4096, 96)
+	master := PrivateKey{
+		kL:        [32]byte(data[:32]),
+		kR:        [32]byte(data[32:64]),
+		chainCode: [32]byte(data[64:]),
+	}
+	// clear the lowest 3 bits and the highest 3 bits, and then set the second highest bit
+	master.kL[0] &= 0xf8
+	master.kL[31] &= 0x1f
+	master.kL[31] |= 0x40
+	return &master
+}
+
+func uint32ToBytesLE(a uint32) [4]byte {
+	var result [4]byte
+	binary.LittleEndian.PutUint32(result[:], a)
+	return result
+}
+
+// hmacThing computes HMAC-SHA512(chainCode, prefix || keyElement || childIdx), where childIdx is encoded in little-endian.
+func hmacThing(chainCode [32]byte, prefix byte, keyElement []byte, childIdx uint32) [64]byte {
+	hash := hmac.New(sha512.New, chainCode[:])
+	_, _ = hash.Write([]byte{prefix})
+	_, _ = hash.Write(keyElement)
+	value := uint32ToBytesLE(childIdx)
+	_, _ = hash.Write(value[:])
+	return [64]byte(hash.Sum(nil))
+}
+
+// mul8Trunc28 returns 8 * zl[:28] as a 256-bit little-endian integer.
+func mul8Trunc28(zl [32]byte) [32]byte {
+	var result [32]byte
+	var prev byte
+	for i := 0; i < 28; i++ {
+		result[i] = zl[i]<<3 | prev>>5
+		prev = zl[i]
+	}
+	result[28] = prev >> 5
+	return result
+}
+
+// addLE returns (a + b) mod 2^256, where a and b are little-endian integers. It runs in constant-time.
+func addLE(a [32]byte, b [32]byte) [32]byte {
+	var result [32]byte
+	var carry uint16
+	for i := 0; i < 32; i++ {
+		sum := uint16(a[i]) + uint16(b[i]) + carry
+		result[i] = byte(sum)
+		carry = sum >> 8
+	}
+	return result
+}
+
+// scalarFromLE reduces a 256-bit little-endian integer modulo the order of the base point.
+func scalarFromLE(a [32]byte) *edwards25519.Scalar {
+	var wide [64]byte
+	copy(wide[:], a[:])
+	// SetUniformBytes never fails with a 64-byte input.
+	s, _ := edwards25519.NewScalar().SetUniformBytes(wide[:])
+	return s
+}
@@ -0,0 +1,108 @@
+package bip32ed25519
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func suppress[T any](a T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// Test vectors from https://github.com/cardano-foundation/CIPs/blob/master/CIP-0003/Icarus.md
+func TestNewMasterKeyIcarus(t *testing.T) {
+	tests := []struct {
+		entropy    string
+		passphrase string
+		xprv       string
+	}{
+		{
+			entropy:    "46e62370a138a182a498b8e2885bc032379ddf38",
+			passphrase: "",
+			xprv:       "c065afd2832cd8b087c4d9ab7011f481ee1e0721e78ea5dd609f3ab3f156d245d176bd8fd4ec60b4731c3918a2a72a0226c0cd119ec35b47e4d55884667f552a23f7fdcd4a10c6cd2c7393ac61d877873e248f417634aa3d812af327ffe9d620",
+		},
+		{
+			entropy:    "46e62370a138a182a498b8e2885bc032379ddf38",
+			passphrase: "foo",
+			xprv:       "70531039904019351e1afb361cd1b312a4d0565d4ff9f8062d38acf4b15cce41d7b5738d9c893feea55512a3004acb0d222c35d3e3d5cde943a15a9824cbac59443cf67e589614076ba01e354b1a432e0e6db3b59e37fc56b5fb0222970a010e",
+		},
+	}
+	for _, test := range tests {
+		master := NewMasterKeyIcarus(suppress(hex.DecodeString(test.entropy)), []byte(test.passphrase))
+		serialized := master.Serialize()
+		assert.Equal(t, test.xprv, hex.EncodeToString(serialized[:]))
+	}
+}
+
+func TestDerivation(t *testing.T) {
+	master, err := DeserializePrivateKey([PrivateKeyLengthInBytes]byte(suppress(hex.DecodeString("c065afd2832cd8b087c4d9ab7011f481ee1e0721e78ea5dd609f3ab3f156d245d176bd8fd4ec60b4731c3918a2a72a0226c0cd119ec35b47e4d55884667f552a23f7fdcd4a10c6cd2c7393ac61d877873e248f417634aa3d812af327ffe9d620"))))
+	assert.Nil(t, err)
+	masterPub := master.GetPublicKey().Serialize()
+	assert.Equal(t, "757e95578798ef733ad93be322fb043053d56b445d3fe502bcf7cb4a6b0f0c6a23f7fdcd4a10c6cd2c7393ac61d877873e248f417634aa3d812af327ffe9d620", hex.EncodeToString(masterPub[:]))
+
+	// m/1852'/1815'/0'
+	account := master
+	for _, index := range []uint32{FirstHardenedChildIndex + 1852, FirstHardenedChildIndex + 1815, FirstHardenedChildIndex + 0} {
+		account, err = account.NewChildKey(index)
+		assert.Nil(t, err)
+	}
+	accountPrv := account.Serialize()
+	accountPub := account.GetPublicKey().Serialize()
+	assert.Equal(t, "f80081fa05eece83236e612463aafad20d6b92eee67479a1977959540057d2452173fe9a0fccf61cf2cc7c52638f2ded6c08002a71424ca5b93681ee7a385828332b13689518700be3c6d330d72490c42e8a98b7495889a27851e543319fb095", hex.EncodeToString(accountPrv[:]))
+	assert.Equal(t, "7f376415131590bf8cc88e8466fd24a6f95eebd6c2271d89cb51a81402618c9b332b13689518700be3c6d330d72490c42e8a98b7495889a27851e543319fb095", hex.EncodeToString(accountPub[:]))
+
+	// m/1852'/1815'/0'/0/0, derived from both the private key and the public key
+	childPrv := account
+	childPub := account.GetPublicKey()
+	for _, index := range []uint32{0, 0} {
+		childPrv, err = childPrv.NewChildKey(index)
+		assert.Nil(t, err)
+		childPub, err = childPub.NewChildKey(index)
+		assert.Nil(t, err)
+	}
+	childPrvBytes := childPrv.Serialize()
+	assert.Equal(t, "00df3ecf0e02979dd9ee569d09412c1f370f476054aaa1ef3cf5a08c0557d245a6ad0fe81ab55e36178f5866dc8f83cf57239fdeee35c737ef887964aae205002b2dd0a9b83141f6650c40abec9ed52ecaa6a567825cb2c7a14b9452bca0c020", hex.EncodeToString(childPrvBytes[:]))
+	assert.Equal(t, childPrv.GetPublicKey(), childPub)
+	childPubBytes := childPub.Serialize()
+	assert.Equal(t, "cc9809944150c00f3913cd2b103e9b42fe6243fc36a76f9eb800692e2bda3f2e2b2dd0a9b83141f6650c40abec9ed52ecaa6a567825cb2c7a14b9452bca0c020", hex.EncodeToString(childPubBytes[:]))
+
+	deserialized, err := DeserializePublicKey(childPubBytes)
+	assert.Nil(t, err)
+	assert.Equal(t, childPub, deserialized)
+}
+
+func TestHardenedPublicChildKey(t *testing.T) {
+	master := NewMasterKeyIcarus(make([]byte, 16), nil)
+	child, err := master.GetPublicKey().NewChildKey(FirstHardenedChildIndex)
+	assert.Nil(t, child)
+	assert.Equal(t, ErrorHardenedPublicChildKey, err)
+}
+
+func TestDeserializePrivateKeyFailure(t *testing.T) {
+	master := NewMasterKeyIcarus(make([]byte, 16), nil).Serialize()
+	for _, mutate := range []func(*[PrivateKeyLengthInBytes]byte){
+		func(data *[PrivateKeyLengthInBytes]byte) { data[0] |= 0x01 },
+		func(data *[PrivateKeyLengthInBytes]byte) { data[31] |= 0x80 },
+		func(data *[PrivateKeyLengthInBytes]byte) { data[31] &= 0xbf },
+	} {
+		data := master
+		mutate(&data)
+		priv, err := DeserializePrivateKey(data)
+		assert.Nil(t, priv)
+		assert.Equal(t, ErrorInvalidPrivateKey, err)
+	}
+}
+
+func TestDeserializePublicKeyFailure(t *testing.T) {
+	var data [PublicKeyLengthInBytes]byte
+	// y = 2 is not on the curve
+	data[0] = 2
+	pub, err := DeserializePublicKey(data)
+	assert.Nil(t, pub)
+	assert.Equal(t, ErrorInvalidPublicKey, err)
+}
@@ -0,0 +1,98 @@
+package bip32ed25519
+
+import (
+	"crypto/subtle"
+
+	"filippo.io/edwards25519"
+)
+
+// PrivateKey is an extended private key (k_L, k_R, chain code).
+type PrivateKey struct {
+	kL        [32]byte // little-endian, not reduced modulo the order of the base point
+	kR        [32]byte
+	chainCode [32]byte
+}
+
+// KL returns k_L, the scalar part of this PrivateKey in little-endian. The public key is k_L * B.
+func (p *PrivateKey) KL() [32]byte {
+	return p.kL
+}
+
+// KR returns k_R, the part of this PrivateKey used for deriving nonces in signing.
+func (p *PrivateKey) KR() [32]byte {
+	return p.kR
+}
+
+// ChainCode returns the chain code of this PrivateKey. This value is used in derivation of child keys.
+func (p *PrivateKey) ChainCode() [32]byte {
+	return p.chainCode
+}
+
+// GetPublicKey finds the corresponding PublicKey from this PrivateKey.
+func (p *PrivateKey) GetPublicKey() *PublicKey {
+	publicKey := PublicKey{
+		chainCode: p.chainCode,
+		publicKey: p.publicKeyBytes(),
+	}
+	return &publicKey
+}
+
+func (p *PrivateKey) publicKeyBytes() [32]byte {
+	var a edwards25519.Point
+	a.ScalarBaseMult(scalarFromLE(p.kL))
+	return [32]byte(a.Bytes())
+}
+
+// Serialize returns the []byte representation (k_L || k_R || chain code) of this PrivateKey.
+func (p *PrivateKey) Serialize() [PrivateKeyLengthInBytes]byte {
+	var result [PrivateKeyLengthInBytes]byte
+	copy(result[:32], p.kL[:])
+	copy(result[32:64], p.kR[:])
+	copy(result[64:], p.chainCode[:])
+	return result
+}
+
+// DeserializePrivateKey reads a []byte (k_L || k_R || chain code) and
+// returns a PrivateKey. ErrorInvalidPrivateKey is returned if
+// the lowest 3 bits of k_L are not cleared, the highest bit of k_L is not cleared,
+// the second highest bit of k_L is not set, or k_L is a multiple of the order of the base point.
+func DeserializePrivateKey(data [PrivateKeyLengthInBytes]byte) (*PrivateKey, error) {
+	p := PrivateKey{
+		kL:        [32]byte(data[:32]),
+		kR:        [32]byte(data[32:64]),
+		chainCode: [32]byte(data[64:]),
+	}
+	valid := subtle.ConstantTimeByteEq(p.kL[0]&0x07, 0) &
+		subtle.ConstantTimeByteEq(p.kL[31]&0xc0, 0x40) &
+		(scalarFromLE(p.kL).Equal(edwards25519.NewScalar()) ^ 1)
+	if valid != 1 {
+		return nil, ErrorInvalidPrivateKey
+	}
+	return &p, nil
+}
+
+// NewChildKey derives a new child key from this PrivateKey. The following errors may be returned:
+//   - ErrorInvalidPrivateKey: if the derived k_L is a multiple of the order of the base point (with negligible probability)
+func (p *PrivateKey) NewChildKey(childIdx uint32) (*PrivateKey, error) {
+	var z, c [64]byte
+	if childIdx >= FirstHardenedChildIndex {
+		keyData := make([]byte, 64)
+		copy(keyData[:32], p.kL[:])
+		copy(keyData[32:], p.kR[:])
+		z = hmacThing(p.chainCode, 0x00, keyData, childIdx)
+		c = hmacThing(p.chainCode, 0x01, keyData, childIdx)
+	} else {
+		pub := p.publicKeyBytes()
+		z = hmacThing(p.chainCode, 0x02, pub[:], childIdx)
+		c = hmacThing(p.chainCode, 0x03, pub[:], childIdx)
+	}
+	child := PrivateKey{
+		kL:        addLE(mul8Trunc28([32]byte(z[:32])), p.kL),
+		kR:        addLE([32]byte(z[32:]), p.kR),
+		chainCode: [32]byte(c[32:]),
+	}
+	if scalarFromLE(child.kL).Equal(edwards25519.NewScalar()) == 1 {
+		return nil, ErrorInvalidPrivateKey
+	}
+	return &child, nil
+}
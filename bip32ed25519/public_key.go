@@ -0,0 +1,69 @@
+package bip32ed25519
+
+import (
+	"filippo.io/edwards25519"
+)
+
+// PublicKey is an extended public key (A, chain code).
+type PublicKey struct {
+	chainCode [32]byte
+	publicKey [32]byte // an encoded point on edwards25519
+}
+
+// ChainCode returns the chain code of this PublicKey. This value is used in derivation of child public keys.
+func (p *PublicKey) ChainCode() [32]byte {
+	return p.chainCode
+}
+
+// PublicKey returns the public key of ed25519 (an encoded point) in this PublicKey.
+func (p *PublicKey) PublicKey() [32]byte {
+	return p.publicKey
+}
+
+// Serialize returns the []byte representation (A || chain code) of this PublicKey.
+func (p *PublicKey) Serialize() [PublicKeyLengthInBytes]byte {
+	var result [PublicKeyLengthInBytes]byte
+	copy(result[:32], p.publicKey[:])
+	copy(result[32:], p.chainCode[:])
+	return result
+}
+
+// DeserializePublicKey reads a []byte (A || chain code) and
+// returns a PublicKey.
+func DeserializePublicKey(data [PublicKeyLengthInBytes]byte) (*PublicKey, error) {
+	p := PublicKey{
+		publicKey: [32]byte(data[:32]),
+		chainCode: [32]byte(data[32:]),
+	}
+	// checks if p.publicKey is valid
+	if _, err := new(edwards25519.Point).SetBytes(p.publicKey[:]); err != nil {
+		return nil, ErrorInvalidPublicKey
+	}
+	return &p, nil
+}
+
+// NewChildKey derives a new child key from this PublicKey. The following errors may be returned:
+//   - ErrorHardenedPublicChildKey: if childIdx >= FirstHardenedChildIndex = 0x80000000
+//   - ErrorInvalidPublicKey: if this PublicKey is invalid or the derived public key is the identity (with negligible probability)
+func (p *PublicKey) NewChildKey(childIdx uint32) (*PublicKey, error) {
+	if childIdx >= FirstHardenedChildIndex {
+		return nil, ErrorHardenedPublicChildKey
+	}
+	a, err := new(edwards25519.Point).SetBytes(p.publicKey[:])
+	if err != nil {
+		return nil, ErrorInvalidPublicKey
+	}
+	z := hmacThing(p.chainCode, 0x02, p.publicKey[:], childIdx)
+	c := hmacThing(p.chainCode, 0x03, p.publicKey[:], childIdx)
+	var tweak, derived edwards25519.Point
+	tweak.ScalarBaseMult(scalarFromLE(mul8Trunc28([32]byte(z[:32]))))
+	derived.Add(a, &tweak)
+	if derived.Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return nil, ErrorInvalidPublicKey
+	}
+	child := PublicKey{
+		chainCode: [32]byte(c[32:]),
+		publicKey: [32]byte(derived.Bytes()),
+	}
+	return &child, nil
+}
@@ -0,0 +1,365 @@
+package bip32
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"strings"
+
+	"github.com/koba-e964/base58-go"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Passphrase-protected private keys of BIP 38.
+//
+// Spec: https://github.com/bitcoin/bips/blob/master/bip-0038.mediawiki
+//
+// BIP 38 requires passphrases to be normalized in NFC. This package does not normalize them;
+// callers that accept non-ASCII passphrases should normalize them, for example with golang.org/x/text/unicode/norm.
+
+const (
+	bip38Length                 = 58 // 6P...
+	bip38IntermediateCodeLength = 72 // passphrase...
+	bip38ConfirmationCodeLength = 75 // cfrm38...
+
+	bip38FlagNonECMultiply = 0xc0
+	bip38FlagCompressed    = 0x20
+	bip38FlagLotSequence   = 0x04
+)
+
+var (
+	bip38NonECMultiplyPrefix = []byte{0x01, 0x42}
+	bip38ECMultiplyPrefix    = []byte{0x01, 0x43}
+	// followed by 0x51 if lot and sequence numbers are used, 0x53 otherwise
+	intermediateCodeMagic = []byte{0x2c, 0xe9, 0xb3, 0xe1, 0xff, 0x39, 0xe2}
+	confirmationCodeMagic = []byte{0x64, 0x3b, 0xf6, 0xa8, 0x9a}
+)
+
+// BIP38Encrypt encrypts this WIFKey with passphrase, without EC multiplication.
+// The result is a 58-character string starting with "6P".
+//
+// The address hash in the result is of the P2PKH address on mainnet even if this WIFKey is for testnet,
+// since BIP 38 is defined only for mainnet.
+func (w *WIFKey) BIP38Encrypt(passphrase string) string {
+	flag := byte(bip38FlagNonECMultiply)
+	if w.compressed {
+		flag |= bip38FlagCompressed
+	}
+	addressHash := bip38AddressHash(w.PublicKey(), w.compressed)
+	derived := bip38Scrypt([]byte(passphrase), addressHash[:], 16384, 8, 8)
+	block := newBIP38Cipher(derived[32:])
+	data := append(append(append([]byte{}, bip38NonECMultiplyPrefix...), flag), addressHash[:]...)
+	privateKey := w.privateKey.Declassify()
+	defer clear(privateKey[:])
+	var half, encryptedHalf [16]byte
+	for i := 0; i < 2; i++ {
+		subtle.XORBytes(half[:], privateKey[16*i:16*i+16], derived[16*i:16*i+16])
+		block.Encrypt(encryptedHalf[:], half[:])
+		data = append(data, encryptedHalf[:]...)
+	}
+	return base58CheckEncode(data, bip38Length)
+}
+
+// BIP38Decrypt decrypts a key encrypted with passphrase, with or without EC multiplication. The returned WIFKey is for mainnet.
+// The following errors may be returned:
+//   - ErrorInvalidBIP38: if encrypted is malformed
+//   - ErrorChecksumMismatch: if the checksum is wrong
+//   - ErrorPassphraseMismatch: if passphrase is wrong
+//   - ErrorPrivateKeyNotInRange: if the decrypted key or an intermediate scalar is not in 1 <= p <= n-1
+func BIP38Decrypt(encrypted string, passphrase string) (*WIFKey, error) {
+	if len(encrypted) != bip38Length {
+		return nil, ErrorInvalidBIP38
+	}
+	data, err := base58CheckDecode(encrypted, 39, ErrorInvalidBIP38)
+	if err != nil {
+		return nil, err
+	}
+	flag := data[2]
+	addressHash := data[3:7]
+	w := WIFKey{compressed: flag&bip38FlagCompressed != 0}
+	switch {
+	case bytes.Equal(data[:2], bip38NonECMultiplyPrefix) && flag&^bip38FlagCompressed == bip38FlagNonECMultiply:
+		derived := bip38Scrypt([]byte(passphrase), addressHash, 16384, 8, 8)
+		block := newBIP38Cipher(derived[32:])
+		var half [16]byte
+		var privateKey secp256k1.Scalar
+		for i := 0; i < 2; i++ {
+			block.Decrypt(half[:], data[7+16*i:23+16*i])
+			subtle.XORBytes(privateKey[16*i:16*i+16], half[:], derived[16*i:16*i+16])
+		}
+		w.privateKey = secp256k1.NewSecretScalar(privateKey)
+		clear(privateKey[:])
+	case bytes.Equal(data[:2], bip38ECMultiplyPrefix) && flag&^(bip38FlagCompressed|bip38FlagLotSequence) == 0:
+		ownerEntropy := [8]byte(data[7:15])
+		passFactor, passPoint, err := bip38PassFactor(passphrase, ownerEntropy, flag&bip38FlagLotSequence != 0)
+		if err != nil {
+			return nil, err
+		}
+		derived := bip38Scrypt(passPoint[:], data[3:15], 1024, 1, 1)
+		block := newBIP38Cipher(derived[32:])
+		var seedB [24]byte
+		var half, encryptedPart1 [16]byte
+		// encryptedpart2 is the encryption of (encryptedpart1[8:16] || seedb[16:24])
+		block.Decrypt(half[:], data[23:39])
+		subtle.XORBytes(half[:], half[:], derived[16:32])
+		copy(encryptedPart1[:8], data[15:23])
+		copy(encryptedPart1[8:], half[:8])
+		copy(seedB[16:], half[8:])
+		block.Decrypt(half[:], encryptedPart1[:])
+		subtle.XORBytes(seedB[:16], half[:], derived[:16])
+		factorB := secp256k1.NewSecretScalar(doubleSHA256(seedB[:]))
+		if isPrivateKeyInRange(factorB) != 1 {
+			return nil, ErrorPrivateKeyNotInRange
+		}
+		w.privateKey = passFactor.Mul(factorB)
+	default:
+		return nil, ErrorInvalidBIP38
+	}
+	if isPrivateKeyInRange(w.privateKey) != 1 {
+		return nil, ErrorPrivateKeyNotInRange
+	}
+	expected := bip38AddressHash(w.PublicKey(), w.compressed)
+	if subtle.ConstantTimeCompare(expected[:], addressHash) != 1 {
+		return nil, ErrorPassphraseMismatch
+	}
+	return &w, nil
+}
+
+// NewBIP38IntermediateCode makes an intermediate code from passphrase with a random salt and without lot and sequence numbers.
+// The intermediate code can be given to another party, who generates keys encrypted with passphrase by BIP38EncryptWithIntermediateCode
+// without learning the private keys.
+func NewBIP38IntermediateCode(passphrase string) (string, error) {
+	var ownerEntropy [8]byte
+	if _, err := rand.Read(ownerEntropy[:]); err != nil {
+		return "", err
+	}
+	return bip38IntermediateCode(passphrase, ownerEntropy, false)
+}
+
+// NewBIP38IntermediateCodeWithLotSequence makes an intermediate code from passphrase with a random salt, lot and sequence.
+// lot must be < 2^20 and sequence must be < 2^12; otherwise ErrorLotSequenceOutOfRange is returned.
+func NewBIP38IntermediateCodeWithLotSequence(passphrase string, lot uint32, sequence uint32) (string, error) {
+	if lot >= 1<<20 || sequence >= 1<<12 {
+		return "", ErrorLotSequenceOutOfRange
+	}
+	var ownerEntropy [8]byte
+	if _, err := rand.Read(ownerEntropy[:4]); err != nil {
+		return "", err
+	}
+	binary.BigEndian.PutUint32(ownerEntropy[4:], lot<<12|sequence)
+	return bip38IntermediateCode(passphrase, ownerEntropy, true)
+}
+
+func bip38IntermediateCode(passphrase string, ownerEntropy [8]byte, lotSequence bool) (string, error) {
+	_, passPoint, err := bip38PassFactor(passphrase, ownerEntropy, lotSequence)
+	if err != nil {
+		return "", err
+	}
+	magic := byte(0x53)
+	if lotSequence {
+		magic = 0x51
+	}
+	data := append(append([]byte{}, intermediateCodeMagic...), magic)
+	data = append(append(data, ownerEntropy[:]...), passPoint[:]...)
+	return base58CheckEncode(data, bip38IntermediateCodeLength), nil
+}
+
+// BIP38EncryptWithIntermediateCode generates a random key encrypted with the passphrase behind intermediateCode (EC multiplication).
+// The caller does not learn the private key. It returns the encrypted key and a confirmation code,
+// with which the owner of the passphrase can check the address of the key by BIP38VerifyConfirmationCode.
+// The following errors may be returned:
+//   - ErrorInvalidIntermediateCode: if intermediateCode is malformed
+//   - ErrorChecksumMismatch: if the checksum is wrong
+func BIP38EncryptWithIntermediateCode(intermediateCode string, compressed bool) (encrypted string, confirmationCode string, err error) {
+	for {
+		var seedB [24]byte
+		if _, err := rand.Read(seedB[:]); err != nil {
+			return "", "", err
+		}
+		encrypted, confirmationCode, err = bip38EncryptWithIntermediateCode(intermediateCode, compressed, seedB)
+		// retries with another seed if factorb or the generated key is invalid, as BIP 38 requires
+		if err != ErrorPrivateKeyNotInRange {
+			return encrypted, confirmationCode, err
+		}
+	}
+}
+
+func bip38EncryptWithIntermediateCode(intermediateCode string, compressed bool, seedB [24]byte) (string, string, error) {
+	if len(intermediateCode) != bip38IntermediateCodeLength {
+		return "", "", ErrorInvalidIntermediateCode
+	}
+	data, err := base58CheckDecode(intermediateCode, 49, ErrorInvalidIntermediateCode)
+	if err != nil {
+		return "", "", err
+	}
+	if !bytes.Equal(data[:7], intermediateCodeMagic) || (data[7] != 0x51 && data[7] != 0x53) {
+		return "", "", ErrorInvalidIntermediateCode
+	}
+	ownerEntropy := data[8:16]
+	passPointBytes := secp256k1.Compressed(data[16:49])
+	passPoint, err := passPointBytes.Uncompress()
+	if err != nil {
+		return "", "", ErrorInvalidIntermediateCode
+	}
+	flag := byte(0)
+	if compressed {
+		flag |= bip38FlagCompressed
+	}
+	if data[7] == 0x51 {
+		flag |= bip38FlagLotSequence
+	}
+	factorB := secp256k1.NewSecretScalar(doubleSHA256(seedB[:]))
+	if isPrivateKeyInRange(factorB) != 1 {
+		return "", "", ErrorPrivateKeyNotInRange
+	}
+	var generated secp256k1.Point
+	generated.GESecretScalarMult(passPoint, factorB)
+	if generated.IsInfinity() == 1 {
+		return "", "", ErrorPrivateKeyNotInRange
+	}
+	addressHash := bip38AddressHash(generated.Compress(), compressed)
+	salt := append(append([]byte{}, addressHash[:]...), ownerEntropy...)
+	derived := bip38Scrypt(passPointBytes[:], salt, 1024, 1, 1)
+	block := newBIP38Cipher(derived[32:])
+
+	var half, encryptedPart1, encryptedPart2 [16]byte
+	subtle.XORBytes(half[:], seedB[:16], derived[:16])
+	block.Encrypt(encryptedPart1[:], half[:])
+	copy(half[:8], encryptedPart1[8:])
+	copy(half[8:], seedB[16:])
+	subtle.XORBytes(half[:], half[:], derived[16:32])
+	block.Encrypt(encryptedPart2[:], half[:])
+	result := append(append([]byte{}, bip38ECMultiplyPrefix...), flag)
+	result = append(append(result, salt...), encryptedPart1[:8]...)
+	result = append(result, encryptedPart2[:]...)
+
+	var pointB secp256k1.Point
+	pointB.GESecretPoint(factorB)
+	pointBBytes := pointB.Compress()
+	confirmation := append(append([]byte{}, confirmationCodeMagic...), flag)
+	confirmation = append(append(confirmation, salt...), pointBBytes[0]^(derived[63]&1))
+	for i := 0; i < 2; i++ {
+		subtle.XORBytes(half[:], pointBBytes[1+16*i:17+16*i], derived[16*i:16*i+16])
+		block.Encrypt(encryptedPart1[:], half[:])
+		confirmation = append(confirmation, encryptedPart1[:]...)
+	}
+	return base58CheckEncode(result, bip38Length), base58CheckEncode(confirmation, bip38ConfirmationCodeLength), nil
+}
+
+// BIP38VerifyConfirmationCode checks confirmationCode with passphrase and returns the P2PKH address of the generated key.
+// The following errors may be returned:
+//   - ErrorInvalidConfirmationCode: if confirmationCode is malformed
+//   - ErrorChecksumMismatch: if the checksum is wrong
+//   - ErrorPassphraseMismatch: if passphrase is wrong
+func BIP38VerifyConfirmationCode(confirmationCode string, passphrase string) (string, error) {
+	if len(confirmationCode) != bip38ConfirmationCodeLength {
+		return "", ErrorInvalidConfirmationCode
+	}
+	data, err := base58CheckDecode(confirmationCode, 51, ErrorInvalidConfirmationCode)
+	if err != nil {
+		return "", err
+	}
+	flag := data[5]
+	if !bytes.Equal(data[:5], confirmationCodeMagic) || flag&^(bip38FlagCompressed|bip38FlagLotSequence) != 0 {
+		return "", ErrorInvalidConfirmationCode
+	}
+	passFactor, passPoint, err := bip38PassFactor(passphrase, [8]byte(data[10:18]), flag&bip38FlagLotSequence != 0)
+	if err != nil {
+		return "", err
+	}
+	derived := bip38Scrypt(passPoint[:], data[6:18], 1024, 1, 1)
+	block := newBIP38Cipher(derived[32:])
+	var pointBBytes secp256k1.Compressed
+	pointBBytes[0] = data[18] ^ (derived[63] & 1)
+	var half [16]byte
+	for i := 0; i < 2; i++ {
+		block.Decrypt(half[:], data[19+16*i:35+16*i])
+		subtle.XORBytes(pointBBytes[1+16*i:17+16*i], half[:], derived[16*i:16*i+16])
+	}
+	// a wrong passphrase yields a random x-coordinate, which is not on the curve with probability 1/2
+	pointB, err := pointBBytes.Uncompress()
+	if err != nil {
+		return "", ErrorPassphraseMismatch
+	}
+	var generated secp256k1.Point
+	generated.GESecretScalarMult(pointB, passFactor)
+	address := p2pkhAddress(bip38PublicKeyBytes(generated.Compress(), flag&bip38FlagCompressed != 0))
+	if hash := checksum([]byte(address)); !bytes.Equal(hash[:], data[6:10]) {
+		return "", ErrorPassphraseMismatch
+	}
+	return address, nil
+}
+
+// bip38PassFactor computes passfactor and passpoint from passphrase and ownerentropy.
+func bip38PassFactor(passphrase string, ownerEntropy [8]byte, lotSequence bool) (secp256k1.SecretScalar, secp256k1.Compressed, error) {
+	ownerSalt := ownerEntropy[:]
+	if lotSequence {
+		ownerSalt = ownerEntropy[:4]
+	}
+	prefactor := secp256k1.Scalar(bip38Scrypt([]byte(passphrase), ownerSalt, 16384, 8, 8)[:32])
+	if lotSequence {
+		prefactor = secp256k1.Scalar(doubleSHA256(append(prefactor[:], ownerEntropy[:]...)))
+	}
+	passFactor := secp256k1.NewSecretScalar(prefactor)
+	clear(prefactor[:])
+	if isPrivateKeyInRange(passFactor) != 1 {
+		return secp256k1.SecretScalar{}, secp256k1.Compressed{}, ErrorPrivateKeyNotInRange
+	}
+	var passPoint secp256k1.Point
+	passPoint.GESecretPoint(passFactor)
+	return passFactor, passPoint.Compress(), nil
+}
+
+// bip38Scrypt returns 64 bytes derived from passphrase and salt by scrypt.
+func bip38Scrypt(passphrase []byte, salt []byte, n int, r int, p int) []byte {
+	derived, err := scrypt.Key(passphrase, salt, n, r, p, 64)
+	if err != nil {
+		// unreachable: the parameters are constants
+		panic(err)
+	}
+	return derived
+}
+
+func newBIP38Cipher(key []byte) cipher.Block {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		// unreachable: key is 32 bytes
+		panic(err)
+	}
+	return block
+}
+
+// bip38AddressHash returns the first 4 bytes of SHA256(SHA256(address)), where address is the P2PKH address of publicKey.
+func bip38AddressHash(publicKey secp256k1.Compressed, compressed bool) [4]byte {
+	return checksum([]byte(p2pkhAddress(bip38PublicKeyBytes(publicKey, compressed))))
+}
+
+func bip38PublicKeyBytes(publicKey secp256k1.Compressed, compressed bool) []byte {
+	if compressed {
+		return publicKey[:]
+	}
+	point, err := publicKey.Uncompress()
+	if err != nil {
+		// unreachable: publicKey is computed from a private key
+		panic(err)
+	}
+	uncompressed := point.SerializeUncompressed()
+	return uncompressed[:]
+}
+
+// p2pkhAddress returns the P2PKH address of publicKey on mainnet.
+func p2pkhAddress(publicKey []byte) string {
+	data := append([]byte{0x00}, hash160(publicKey)...)
+	chksum := checksum(data)
+	data = append(data, chksum[:]...)
+	zeros := 0
+	for zeros < len(data) && data[zeros] == 0 {
+		zeros++
+	}
+	// 58^35 > 2^200, so 35 characters suffice for 25 bytes
+	return strings.Repeat("1", zeros) + strings.TrimLeft(base58.VartimeEncode(data, 35), "1")
+}
@@ -0,0 +1,273 @@
+package bip32
+
+import (
+	"container/list"
+	"encoding/binary"
+	"sync"
+)
+
+// DerivationCache caches keys derived from root keys, so that deriving many paths with a common prefix,
+// such as m/84'/0'/0'/0/i for many i, derives the prefix only once.
+// Entries are keyed by the fingerprint of the root key and the path, and the least recently used entries are evicted
+// when the cache is full.
+//
+// If the cache is public-only, it holds only public keys: private derivation through it is not cached,
+// and public keys of hardened paths are derived from the private root once and then kept without private material.
+//
+// A DerivationCache is safe for concurrent use.
+type DerivationCache struct {
+	mu         sync.Mutex
+	capacity   int
+	publicOnly bool
+	entries    map[cacheKey]*list.Element
+	lru        *list.List // front is the most recently used; values are *cacheEntry
+}
+
+type cacheKey struct {
+	rootFingerprint [4]byte
+	path            string // child indices in big-endian
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	root rootIdentity
+	// privateKey is nil if this entry was derived from a public key or the cache is public-only
+	privateKey *PrivateKey
+	// publicKey is computed from privateKey on demand, since it costs a scalar multiplication
+	publicKey *PublicKey
+}
+
+// rootIdentity distinguishes root keys with the same fingerprint, which can collide.
+// It also includes the metadata of the root key, which is inherited by derived keys.
+type rootIdentity struct {
+	serialized [KeyLengthInBytes]byte // the serialization of the public root key
+	origin     string                 // "" if unknown
+}
+
+// NewDerivationCache returns a DerivationCache holding at most capacity keys.
+// If publicOnly is true, the cache never holds private keys.
+// ErrorInvalidCacheCapacity is returned if capacity is not positive.
+func NewDerivationCache(capacity int, publicOnly bool) (*DerivationCache, error) {
+	if capacity <= 0 {
+		return nil, ErrorInvalidCacheCapacity
+	}
+	return &DerivationCache{
+		capacity:   capacity,
+		publicOnly: publicOnly,
+		entries:    map[cacheKey]*list.Element{},
+		lru:        list.New(),
+	}, nil
+}
+
+// Len returns the number of cached keys.
+func (c *DerivationCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// Clear removes all cached keys.
+func (c *DerivationCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[cacheKey]*list.Element{}
+	c.lru.Init()
+}
+
+func encodePath(path []uint32) string {
+	result := make([]byte, 4*len(path))
+	for i, childIdx := range path {
+		binary.BigEndian.PutUint32(result[4*i:], childIdx)
+	}
+	return string(result)
+}
+
+func identityOf(root *PublicKey) rootIdentity {
+	identity := rootIdentity{serialized: root.Serialize()}
+	if origin := root.KeyOrigin(); origin != nil {
+		identity.origin = origin.String()
+	}
+	return identity
+}
+
+// get returns the cached keys of path from root. privateKey is nil if the entry has no private key.
+// If needPublic is true, the public key is computed and cached if it is not yet.
+func (c *DerivationCache) get(fingerprint [4]byte, root rootIdentity, path []uint32, needPublic bool) (privateKey *PrivateKey, publicKey *PublicKey, ok bool) {
+	key := cacheKey{rootFingerprint: fingerprint, path: encodePath(path)}
+	privateKey, publicKey, ok = c.lookup(key, root)
+	if !ok || !needPublic || publicKey != nil {
+		return privateKey, publicKey, ok
+	}
+	// the scalar multiplication is done without the lock, so that it doesn't block other goroutines
+	publicKey = privateKey.GetPublicKey()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, found := c.entries[key]; found {
+		entry := element.Value.(*cacheEntry)
+		if entry.privateKey == privateKey && entry.publicKey == nil {
+			entry.publicKey = publicKey
+		}
+	}
+	return privateKey, publicKey, true
+}
+
+// lookup returns the cached keys of key and marks the entry as the most recently used.
+func (c *DerivationCache) lookup(key cacheKey, root rootIdentity) (*PrivateKey, *PublicKey, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if entry.root != root {
+		return nil, nil, false
+	}
+	c.lru.MoveToFront(element)
+	return entry.privateKey, entry.publicKey, true
+}
+
+// put caches a key of path from root. An entry with a private key is not replaced by an entry without one.
+// publicKey may be nil if privateKey is given.
+func (c *DerivationCache) put(fingerprint [4]byte, root rootIdentity, path []uint32, privateKey *PrivateKey, publicKey *PublicKey) {
+	if c.publicOnly && privateKey != nil {
+		if publicKey == nil {
+			publicKey = privateKey.GetPublicKey()
+		}
+		privateKey = nil
+	}
+	key := cacheKey{rootFingerprint: fingerprint, path: encodePath(path)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*cacheEntry)
+		if entry.root != root || entry.privateKey == nil {
+			entry.root = root
+			entry.privateKey = privateKey
+			entry.publicKey = publicKey
+		}
+		c.lru.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, root: root, privateKey: privateKey, publicKey: publicKey})
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+		c.lru.Remove(oldest)
+	}
+}
+
+// DerivePrivate derives the private key at path from root, using and filling the cache.
+// If the cache is public-only, the key is derived without the cache.
+func (c *DerivationCache) DerivePrivate(root *PrivateKey, path []uint32) (*PrivateKey, error) {
+	if c.publicOnly {
+		return derivePrivatePath(root, path)
+	}
+	rootPublic := root.GetPublicKey()
+	fingerprint := rootPublic.Fingerprint()
+	identity := identityOf(rootPublic)
+	// the longest cached prefix with a private key
+	start, key := 0, root
+	for i := len(path); i > 0; i-- {
+		if privateKey, _, _ := c.get(fingerprint, identity, path[:i], false); privateKey != nil {
+			start, key = i, privateKey
+			break
+		}
+	}
+	for i := start; i < len(path); i++ {
+		child, err := key.NewChildKey(path[i])
+		if err != nil {
+			return nil, err
+		}
+		key = child
+		c.put(fingerprint, identity, path[:i+1], key, nil)
+	}
+	return key, nil
+}
+
+// DerivePublicFromPrivate derives the public key at path from root, using and filling the cache.
+// Hardened steps are derived privately; the steps after the last hardened index are derived publicly.
+func (c *DerivationCache) DerivePublicFromPrivate(root *PrivateKey, path []uint32) (*PublicKey, error) {
+	rootPublic := root.GetPublicKey()
+	fingerprint := rootPublic.Fingerprint()
+	identity := identityOf(rootPublic)
+	lastHardened := lastHardenedIndex(path)
+	if start, key := c.longestPublicPrefix(fingerprint, identity, path, lastHardened+1); key != nil {
+		return c.derivePublicSteps(fingerprint, identity, key, path, start)
+	}
+	// the longest cached prefix with a private key, up to the last hardened index
+	start, privateKey := 0, root
+	for i := lastHardened + 1; i > 0; i-- {
+		if cached, _, _ := c.get(fingerprint, identity, path[:i], false); cached != nil {
+			start, privateKey = i, cached
+			break
+		}
+	}
+	for i := start; i <= lastHardened; i++ {
+		child, err := privateKey.NewChildKey(path[i])
+		if err != nil {
+			return nil, err
+		}
+		privateKey = child
+		c.put(fingerprint, identity, path[:i+1], privateKey, nil)
+	}
+	return c.derivePublicSteps(fingerprint, identity, privateKey.GetPublicKey(), path, lastHardened+1)
+}
+
+// DerivePublic derives the public key at path from root, using and filling the cache.
+// ErrorHardenedPublicChildKey is returned if path has a hardened index.
+func (c *DerivationCache) DerivePublic(root *PublicKey, path []uint32) (*PublicKey, error) {
+	if lastHardenedIndex(path) >= 0 {
+		return nil, ErrorHardenedPublicChildKey
+	}
+	fingerprint := root.Fingerprint()
+	identity := identityOf(root)
+	start, key := c.longestPublicPrefix(fingerprint, identity, path, 0)
+	if key == nil {
+		start, key = 0, root
+	}
+	return c.derivePublicSteps(fingerprint, identity, key, path, start)
+}
+
+// longestPublicPrefix returns the longest cached prefix of path with at least minLength indices, or nil if there is none.
+func (c *DerivationCache) longestPublicPrefix(fingerprint [4]byte, identity rootIdentity, path []uint32, minLength int) (int, *PublicKey) {
+	for i := len(path); i >= minLength && i > 0; i-- {
+		if _, publicKey, ok := c.get(fingerprint, identity, path[:i], true); ok {
+			return i, publicKey
+		}
+	}
+	return 0, nil
+}
+
+func (c *DerivationCache) derivePublicSteps(fingerprint [4]byte, identity rootIdentity, key *PublicKey, path []uint32, start int) (*PublicKey, error) {
+	for i := start; i < len(path); i++ {
+		child, err := key.NewChildKey(path[i])
+		if err != nil {
+			return nil, err
+		}
+		key = child
+		c.put(fingerprint, identity, path[:i+1], nil, key)
+	}
+	return key, nil
+}
+
+// lastHardenedIndex returns the position of the last hardened index in path, or -1 if there is none.
+func lastHardenedIndex(path []uint32) int {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] >= FirstHardenedChildIndex {
+			return i
+		}
+	}
+	return -1
+}
+
+func derivePrivatePath(key *PrivateKey, path []uint32) (*PrivateKey, error) {
+	for _, childIdx := range path {
+		child, err := key.NewChildKey(childIdx)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	return key, nil
+}
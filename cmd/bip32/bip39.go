@@ -0,0 +1,75 @@
+package main
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// BIP 39 mnemonics.
+//
+// Spec: https://github.com/bitcoin/bips/blob/master/bip-0039.mediawiki
+
+//go:embed english.txt
+var englishWordlist string
+
+var (
+	errorInvalidMnemonicLength   = errors.New("mnemonic must have 12, 15, 18, 21 or 24 words")
+	errorUnknownMnemonicWord     = errors.New("mnemonic has a word not in the English wordlist")
+	errorMnemonicChecksum        = errors.New("mnemonic checksum mismatch")
+	errorNonASCIIMnemonicOrInput = errors.New("mnemonic and passphrase must be ASCII, since NFKD normalization is not supported")
+)
+
+var wordIndices = func() map[string]int {
+	result := map[string]int{}
+	for i, word := range strings.Fields(englishWordlist) {
+		result[word] = i
+	}
+	return result
+}()
+
+// mnemonicToSeed checks the checksum of an English mnemonic and returns the 64-byte seed.
+// Non-ASCII input is rejected because BIP 39 requires NFKD normalization, which ASCII strings are invariant under.
+func mnemonicToSeed(mnemonic string, passphrase string) ([]byte, error) {
+	if !isASCII(mnemonic) || !isASCII(passphrase) {
+		return nil, errorNonASCIIMnemonicOrInput
+	}
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, errorInvalidMnemonicLength
+	}
+	// entropy || checksum as a big-endian bit string; the checksum has len(words) / 3 bits
+	bits := make([]byte, (len(words)*11+7)/8)
+	for i, word := range words {
+		index, ok := wordIndices[word]
+		if !ok {
+			return nil, errorUnknownMnemonicWord
+		}
+		for j := 0; j < 11; j++ {
+			if index>>(10-j)&1 == 1 {
+				pos := i*11 + j
+				bits[pos/8] |= 0x80 >> (pos % 8)
+			}
+		}
+	}
+	checksumBits := len(words) / 3
+	entropy := bits[:(len(words)*11-checksumBits)/8]
+	hash := sha256.Sum256(entropy)
+	if hash[0]>>(8-checksumBits) != bits[len(entropy)]>>(8-checksumBits) {
+		return nil, errorMnemonicChecksum
+	}
+	return pbkdf2.Key(sha512.New, strings.Join(words, " "), []byte("mnemonic"+passphrase), 2048, 64)
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
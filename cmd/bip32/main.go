@@ -0,0 +1,380 @@
+// Command bip32 generates, derives, inspects and converts BIP 32 extended keys.
+//
+// Usage:
+//
+//	bip32 master [-format hex|bip39] [-in FILE] [-passphrase-file FILE] [-testnet] [-json]
+//	bip32 derive -path PATH [-address TYPE] [-in FILE] [-json]
+//	bip32 inspect [-in FILE] [-json]
+//	bip32 convert -network mainnet|testnet [-in FILE] [-json]
+//
+// Seeds, mnemonics and keys are read from the file given by -in, or from stdin if it is "-" (default).
+// Secrets are never accepted as command-line arguments, which other users of the system may see.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	bip32 "github.com/koba-e964/bip32-typesafe"
+	"github.com/koba-e964/bip32-typesafe/descriptor"
+)
+
+var (
+	errorUsage                     = errors.New("usage: bip32 master|derive|inspect|convert [flags]; run bip32 COMMAND -h for flags")
+	errorInvalidSeed               = errors.New("seed must be 16 to 64 bytes in hex")
+	errorInvalidFormat             = errors.New("format must be hex or bip39")
+	errorInvalidNetwork            = errors.New("network must be mainnet or testnet")
+	errorInvalidAddressType        = errors.New("address type must be p2pkh, p2sh-p2wpkh, p2wpkh or p2tr")
+	errorInvalidPath               = errors.New("path is invalid")
+	errorAbsolutePathOfChild       = errors.New("path starting with m requires a master key")
+	errorUnknownKey                = errors.New("input is neither an extended private key nor an extended public key")
+	errorPassphraseWithoutMnemonic = errors.New("passphrase is only used with -format bip39")
+	// errorFlags is returned if flags are invalid, which the flag package has already reported
+	errorFlags = errors.New("invalid flags")
+)
+
+// maxInputLength limits the size of inputs, which are at most a mnemonic or a key.
+const maxInputLength = 1 << 16
+
+var addressTypes = map[string]descriptor.AddressType{
+	"p2pkh":       descriptor.AddressP2PKH,
+	"p2sh-p2wpkh": descriptor.AddressP2SHP2WPKH,
+	"p2wpkh":      descriptor.AddressP2WPKH,
+	"p2tr":        descriptor.AddressP2TR,
+}
+
+func main() {
+	err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errorFlags):
+		os.Exit(2)
+	case errors.Is(err, errorUsage):
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "bip32:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errorUsage
+	}
+	commands := map[string]func([]string, io.Reader, *flag.FlagSet) (fields, error){
+		"master":  runMaster,
+		"derive":  runDerive,
+		"inspect": runInspect,
+		"convert": runConvert,
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		return errorUsage
+	}
+	flags := flag.NewFlagSet("bip32 "+args[0], flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	jsonOutput := flags.Bool("json", false, "print the result in JSON")
+	result, err := command(args[1:], stdin, flags)
+	if err != nil {
+		return err
+	}
+	if *jsonOutput {
+		return result.writeJSON(stdout)
+	}
+	return result.writeText(stdout)
+}
+
+func runMaster(args []string, stdin io.Reader, flags *flag.FlagSet) (fields, error) {
+	in := flags.String("in", "-", "the file to read the seed or the mnemonic from, or - for stdin")
+	format := flags.String("format", "hex", "the format of the input: hex (a seed) or bip39 (an English mnemonic)")
+	passphraseFile := flags.String("passphrase-file", "", "the file to read the BIP 39 passphrase from")
+	testnet := flags.Bool("testnet", false, "make a key for testnet (tprv)")
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	input, err := readInput(*in, stdin)
+	if err != nil {
+		return nil, err
+	}
+	var seed []byte
+	switch *format {
+	case "hex":
+		if *passphraseFile != "" {
+			return nil, errorPassphraseWithoutMnemonic
+		}
+		seed, err = hex.DecodeString(input)
+		if err != nil || len(seed) < 16 || len(seed) > 64 {
+			return nil, errorInvalidSeed
+		}
+	case "bip39":
+		var passphrase []byte
+		if *passphraseFile != "" {
+			if passphrase, err = os.ReadFile(*passphraseFile); err != nil {
+				return nil, err
+			}
+		}
+		// a trailing newline is not part of the passphrase
+		seed, err = mnemonicToSeed(input, strings.TrimRight(string(passphrase), "\r\n"))
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errorInvalidFormat
+	}
+	master := bip32.NewMasterKey(seed).WithNetwork(*testnet)
+	fingerprint := master.Fingerprint()
+	return fields{
+		{"xprv", master.B58Serialize()},
+		{"xpub", master.GetPublicKey().B58Serialize()},
+		{"fingerprint", hex.EncodeToString(fingerprint[:])},
+	}, nil
+}
+
+func runDerive(args []string, stdin io.Reader, flags *flag.FlagSet) (fields, error) {
+	in := flags.String("in", "-", "the file to read the extended key from, or - for stdin")
+	pathFlag := flags.String("path", "", "the derivation path, such as m/84'/0'/0'/0/0, or 0/1 relative to the key")
+	addressType := flags.String("address", "", "also print the address of the derived key: p2pkh, p2sh-p2wpkh, p2wpkh or p2tr")
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	if _, ok := addressTypes[*addressType]; *addressType != "" && !ok {
+		return nil, errorInvalidAddressType
+	}
+	privateKey, publicKey, err := readKey(*in, stdin)
+	if err != nil {
+		return nil, err
+	}
+	absolute, path, err := parsePath(*pathFlag)
+	if err != nil {
+		return nil, err
+	}
+	depth := publicKey.Depth()
+	if absolute && depth != 0 {
+		return nil, errorAbsolutePathOfChild
+	}
+	var result fields
+	if privateKey != nil {
+		for _, childIdx := range path {
+			if privateKey, err = privateKey.NewChildKey(childIdx); err != nil {
+				return nil, err
+			}
+		}
+		publicKey = privateKey.GetPublicKey()
+		result = append(result, field{"xprv", privateKey.B58Serialize()})
+	} else {
+		for _, childIdx := range path {
+			if publicKey, err = publicKey.NewChildKey(childIdx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	fingerprint := publicKey.Fingerprint()
+	result = append(result,
+		field{"xpub", publicKey.B58Serialize()},
+		field{"fingerprint", hex.EncodeToString(fingerprint[:])},
+	)
+	if origin := publicKey.KeyOrigin(); origin != nil {
+		result = append(result, field{"origin", "[" + origin.String() + "]"})
+	}
+	if *addressType != "" {
+		network := descriptor.Mainnet
+		if publicKey.IsTestnet() {
+			network = descriptor.Testnet
+		}
+		address, err := descriptor.PublicKeyAddress(publicKey.PublicKey(), addressTypes[*addressType], network)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, field{"address", address})
+	}
+	return result, nil
+}
+
+func runInspect(args []string, stdin io.Reader, flags *flag.FlagSet) (fields, error) {
+	in := flags.String("in", "-", "the file to read the extended key from, or - for stdin")
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	privateKey, publicKey, err := readKey(*in, stdin)
+	if err != nil {
+		return nil, err
+	}
+	network := "mainnet"
+	if publicKey.IsTestnet() {
+		network = "testnet"
+	}
+	kind := "public"
+	if privateKey != nil {
+		kind = "private"
+	}
+	fingerprint := publicKey.Fingerprint()
+	parentFingerprint := publicKey.ParentFingerprint()
+	chainCode := publicKey.ChainCode()
+	pub := publicKey.PublicKey()
+	// the path of a KeyOrigin is formatted after the fingerprint and a slash
+	_, childNumber, _ := strings.Cut((&bip32.KeyOrigin{Path: []uint32{publicKey.ChildNumber()}}).String(), "/")
+	result := fields{
+		{"type", kind},
+		{"network", network},
+		{"depth", int(publicKey.Depth())},
+		{"fingerprint", hex.EncodeToString(fingerprint[:])},
+		{"parent_fingerprint", hex.EncodeToString(parentFingerprint[:])},
+		{"child_number", childNumber},
+		{"chain_code", hex.EncodeToString(chainCode[:])},
+		{"public_key", hex.EncodeToString(pub[:])},
+	}
+	if privateKey != nil {
+		secret := privateKey.PrivateKey()
+		result = append(result, field{"private_key", hex.EncodeToString(secret[:])})
+	}
+	return append(result, field{"xpub", publicKey.B58Serialize()}), nil
+}
+
+func runConvert(args []string, stdin io.Reader, flags *flag.FlagSet) (fields, error) {
+	in := flags.String("in", "-", "the file to read the extended key from, or - for stdin")
+	network := flags.String("network", "", "the network to convert the key to: mainnet or testnet")
+	if err := parseFlags(flags, args); err != nil {
+		return nil, err
+	}
+	if *network != "mainnet" && *network != "testnet" {
+		return nil, errorInvalidNetwork
+	}
+	testnet := *network == "testnet"
+	privateKey, publicKey, err := readKey(*in, stdin)
+	if err != nil {
+		return nil, err
+	}
+	if privateKey != nil {
+		return fields{{"xprv", privateKey.WithNetwork(testnet).B58Serialize()}}, nil
+	}
+	return fields{{"xpub", publicKey.WithNetwork(testnet).B58Serialize()}}, nil
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return errorFlags
+	}
+	if err == nil && flags.NArg() != 0 {
+		fmt.Fprintf(flags.Output(), "unexpected arguments: %q; secrets must be given with -in or stdin\n", flags.Args())
+		return errorFlags
+	}
+	return err
+}
+
+// readInput reads the whole content of path, or stdin if path is "-", without surrounding whitespace.
+func readInput(path string, stdin io.Reader) (string, error) {
+	reader := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		reader = file
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxInputLength))
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(data)), nil
+}
+
+// readKey reads an extended key. privateKey is nil if the key is a public key; publicKey is always non-nil.
+func readKey(path string, stdin io.Reader) (privateKey *bip32.PrivateKey, publicKey *bip32.PublicKey, err error) {
+	input, err := readInput(path, stdin)
+	if err != nil {
+		return nil, nil, err
+	}
+	if privateKey, err := bip32.B58DeserializePrivateKey(input); err == nil {
+		return privateKey, privateKey.GetPublicKey(), nil
+	}
+	if publicKey, err := bip32.B58DeserializePublicKey(input); err == nil {
+		return nil, publicKey, nil
+	}
+	// explains why the key is rejected
+	var problems []string
+	for _, diagnostic := range bip32.Inspect(input).Diagnostics {
+		problem := diagnostic.Message
+		if diagnostic.Suggestion != "" {
+			problem += " (" + diagnostic.Suggestion + ")"
+		}
+		problems = append(problems, problem)
+	}
+	return nil, nil, fmt.Errorf("%w: %s", errorUnknownKey, strings.Join(problems, "; "))
+}
+
+// parsePath parses a derivation path such as m/84'/0'/0' or 0/1. Hardened indices may be marked with ', h or H.
+// absolute is true if the path starts with m.
+func parsePath(path string) (absolute bool, result []uint32, err error) {
+	elements := strings.Split(path, "/")
+	if elements[0] == "m" {
+		absolute = true
+		elements = elements[1:]
+	} else if path == "" {
+		return false, nil, nil
+	}
+	for _, element := range elements {
+		hardened := strings.HasSuffix(element, "'") || strings.HasSuffix(element, "h") || strings.HasSuffix(element, "H")
+		if hardened {
+			element = element[:len(element)-1]
+		}
+		// ParseUint accepts neither signs nor empty strings
+		childIdx, err := strconv.ParseUint(element, 10, 32)
+		if err != nil || uint32(childIdx) >= bip32.FirstHardenedChildIndex {
+			return false, nil, errorInvalidPath
+		}
+		if hardened {
+			childIdx += uint64(bip32.FirstHardenedChildIndex)
+		}
+		result = append(result, uint32(childIdx))
+	}
+	return absolute, result, nil
+}
+
+type field struct {
+	name  string
+	value any // string or int
+}
+
+// fields is the result of a command, which is printed in order.
+type fields []field
+
+func (f fields) writeText(w io.Writer) error {
+	for _, field := range f {
+		if _, err := fmt.Fprintf(w, "%s: %v\n", field.name, field.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f fields) writeJSON(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range f {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(field.name)
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const abandonMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func runWithInput(t *testing.T, input string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(args, strings.NewReader(input), &stdout, &stderr)
+	return stdout.String(), err
+}
+
+func runJSON(t *testing.T, input string, args ...string) map[string]any {
+	t.Helper()
+	output, err := runWithInput(t, input, append(args, "-json")...)
+	assert.Nil(t, err)
+	var result map[string]any
+	assert.Nil(t, json.Unmarshal([]byte(output), &result))
+	return result
+}
+
+func TestMnemonicToSeed(t *testing.T) {
+	// https://github.com/trezor/python-mnemonic/blob/master/vectors.json
+	seed, err := mnemonicToSeed(abandonMnemonic, "TREZOR")
+	assert.Nil(t, err)
+	assert.Equal(t, "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04", hex.EncodeToString(seed))
+	seed, err = mnemonicToSeed("zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote", "TREZOR")
+	assert.Nil(t, err)
+	assert.Equal(t, "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad", hex.EncodeToString(seed))
+
+	_, err = mnemonicToSeed(strings.Replace(abandonMnemonic, "about", "abandon", 1), "")
+	assert.Equal(t, errorMnemonicChecksum, err)
+	_, err = mnemonicToSeed(strings.Replace(abandonMnemonic, "about", "zoo", 1), "")
+	assert.Equal(t, errorMnemonicChecksum, err)
+	_, err = mnemonicToSeed(strings.Replace(abandonMnemonic, "about", "abcdef", 1), "")
+	assert.Equal(t, errorUnknownMnemonicWord, err)
+	_, err = mnemonicToSeed("abandon abandon about", "")
+	assert.Equal(t, errorInvalidMnemonicLength, err)
+	_, err = mnemonicToSeed(abandonMnemonic, "パスワード")
+	assert.Equal(t, errorNonASCIIMnemonicOrInput, err)
+}
+
+func TestMaster(t *testing.T) {
+	// https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#test-vector-1
+	output, err := runWithInput(t, "000102030405060708090a0b0c0d0e0f\n", "master")
+	assert.Nil(t, err)
+	assert.Equal(t, "xprv: xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi\n"+
+		"xpub: xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8\n"+
+		"fingerprint: 3442193e\n", output)
+
+	result := runJSON(t, "000102030405060708090a0b0c0d0e0f", "master", "-testnet")
+	assert.True(t, strings.HasPrefix(result["xprv"].(string), "tprv"))
+	assert.True(t, strings.HasPrefix(result["xpub"].(string), "tpub"))
+
+	// the seed of the mnemonic is used in the test vectors of BIP 84
+	result = runJSON(t, abandonMnemonic+"\n", "master", "-format", "bip39")
+	assert.Equal(t, "73c5da0a", result["fingerprint"])
+
+	// the passphrase is read from a file
+	passphraseFile := filepath.Join(t.TempDir(), "passphrase")
+	assert.Nil(t, os.WriteFile(passphraseFile, []byte("TREZOR\n"), 0o600))
+	mnemonicFile := filepath.Join(t.TempDir(), "mnemonic")
+	assert.Nil(t, os.WriteFile(mnemonicFile, []byte(abandonMnemonic), 0o600))
+	result = runJSON(t, "", "master", "-format", "bip39", "-in", mnemonicFile, "-passphrase-file", passphraseFile)
+	seedResult := runJSON(t, "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04", "master")
+	assert.Equal(t, seedResult, result)
+
+	_, err = runWithInput(t, "0001", "master")
+	assert.Equal(t, errorInvalidSeed, err)
+	_, err = runWithInput(t, "000102030405060708090a0b0c0d0e0f", "master", "-passphrase-file", passphraseFile)
+	assert.Equal(t, errorPassphraseWithoutMnemonic, err)
+	_, err = runWithInput(t, "", "master", "-format", "bip32")
+	assert.Equal(t, errorInvalidFormat, err)
+}
+
+func TestDerive(t *testing.T) {
+	master := runJSON(t, abandonMnemonic, "master", "-format", "bip39")["xprv"].(string)
+	tests := []struct {
+		path        string
+		addressType string
+		address     string
+	}{
+		// https://github.com/bitcoin/bips/blob/master/bip-0084.mediawiki#test-vectors
+		{"m/84'/0'/0'/0/0", "p2wpkh", "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{"m/84h/0h/0h/1/0", "p2wpkh", "bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"},
+		// https://github.com/bitcoin/bips/blob/master/bip-0086.mediawiki#test-vectors
+		{"m/86'/0'/0'/0/0", "p2tr", "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+		{"m/44'/0'/0'/0/0", "p2pkh", "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		{"m/49'/0'/0'/0/0", "p2sh-p2wpkh", "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf"},
+	}
+	for _, test := range tests {
+		result := runJSON(t, master, "derive", "-path", test.path, "-address", test.addressType)
+		assert.Equal(t, test.address, result["address"])
+		assert.Equal(t, "[73c5da0a"+strings.ReplaceAll(strings.TrimPrefix(test.path, "m"), "h", "'")+"]", result["origin"])
+
+		// the account-level public key derives the same address
+		account := runJSON(t, master, "derive", "-path", strings.Join(strings.Split(test.path, "/")[:4], "/"))
+		assert.NotNil(t, account["xprv"])
+		result = runJSON(t, account["xpub"].(string), "derive", "-path", strings.Join(strings.Split(test.path, "/")[4:], "/"), "-address", test.addressType)
+		assert.Equal(t, test.address, result["address"])
+		assert.Nil(t, result["xprv"])
+		// the origin of a deserialized non-master key is unknown
+		assert.Nil(t, result["origin"])
+	}
+
+	// https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#test-vector-1
+	output, err := runWithInput(t, "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi", "derive", "-path", "m/0H/1/2H/2/1000000000")
+	assert.Nil(t, err)
+	assert.Contains(t, output, "xprv: xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76\n")
+	assert.Contains(t, output, "xpub: xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy\n")
+
+	testnetAccount := runJSON(t, master, "derive", "-path", "m/84'/1'/0'")
+	testnetAccount = runJSON(t, testnetAccount["xpub"].(string), "convert", "-network", "testnet")
+	result := runJSON(t, testnetAccount["xpub"].(string), "derive", "-path", "0/0", "-address", "p2wpkh")
+	assert.True(t, strings.HasPrefix(result["address"].(string), "tb1q"))
+
+	account := runJSON(t, master, "derive", "-path", "m/84'/0'/0'")
+	_, err = runWithInput(t, account["xprv"].(string), "derive", "-path", "m/0")
+	assert.Equal(t, errorAbsolutePathOfChild, err)
+	_, err = runWithInput(t, account["xpub"].(string), "derive", "-path", "0'")
+	assert.NotNil(t, err)
+	for _, path := range []string{"m/", "m//0", "0/-1", "2147483648", "m/1''", "x"} {
+		_, err = runWithInput(t, master, "derive", "-path", path)
+		assert.Equal(t, errorInvalidPath, err, path)
+	}
+	_, err = runWithInput(t, master, "derive", "-path", "m/0", "-address", "p2sh")
+	assert.Equal(t, errorInvalidAddressType, err)
+}
+
+func TestInspect(t *testing.T) {
+	// m/0H/1 of the test vector 1
+	key := "xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs"
+	result := runJSON(t, key, "inspect")
+	assert.Equal(t, map[string]any{
+		"type":               "private",
+		"network":            "mainnet",
+		"depth":              float64(2),
+		"fingerprint":        "bef5a2f9",
+		"parent_fingerprint": "5c1bd648",
+		"child_number":       "1",
+		"chain_code":         "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19",
+		"public_key":         "03501e454bf00751f24b1b489aa925215d66af2234e3891c3b21a52bedb3cd711c",
+		"private_key":        "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368",
+		"xpub":               "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+	}, result)
+
+	output, err := runWithInput(t, "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw", "inspect")
+	assert.Nil(t, err)
+	assert.Contains(t, output, "type: public\n")
+	assert.Contains(t, output, "child_number: 0'\n")
+	assert.NotContains(t, output, "private_key")
+
+	_, err = runWithInput(t, key[:110]+"t", "inspect")
+	assert.ErrorIs(t, err, errorUnknownKey)
+	assert.Contains(t, err.Error(), "checksum")
+	_, err = runWithInput(t, key[:100], "inspect")
+	assert.ErrorIs(t, err, errorUnknownKey)
+	assert.Contains(t, err.Error(), "truncated")
+}
+
+func TestConvert(t *testing.T) {
+	key := "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+	testnet := runJSON(t, key, "convert", "-network", "testnet")["xprv"].(string)
+	assert.True(t, strings.HasPrefix(testnet, "tprv"))
+	assert.Equal(t, "testnet", runJSON(t, testnet, "inspect")["network"])
+	assert.Equal(t, key, runJSON(t, testnet, "convert", "-network", "mainnet")["xprv"])
+	_, err := runWithInput(t, key, "convert", "-network", "signet")
+	assert.Equal(t, errorInvalidNetwork, err)
+}
+
+func TestSecretsNotInArguments(t *testing.T) {
+	key := "xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi"
+	for _, command := range []string{"master", "derive", "inspect", "convert"} {
+		_, err := runWithInput(t, "", command, key)
+		assert.Equal(t, errorFlags, err)
+	}
+	_, err := runWithInput(t, "", "sign")
+	assert.Equal(t, errorUsage, err)
+	_, err = runWithInput(t, "")
+	assert.Equal(t, errorUsage, err)
+}
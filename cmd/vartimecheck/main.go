@@ -0,0 +1,16 @@
+// Command vartimecheck reports variable-time operations on secret values with the analyzer of package vartime.
+//
+// Usage:
+//
+//	go build -o /tmp/vartimecheck ./cmd/vartimecheck
+//	go vet -vettool=/tmp/vartimecheck ./...
+package main
+
+import (
+	"github.com/koba-e964/bip32-typesafe/vartime"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(vartime.Analyzer)
+}
@@ -0,0 +1,166 @@
+package descriptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+
+	"github.com/koba-e964/base58-go"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+)
+
+// Network holds the parameters needed to encode addresses.
+type Network struct {
+	PubKeyHashVersion byte   // version byte of P2PKH addresses
+	ScriptHashVersion byte   // version byte of P2SH addresses
+	Bech32HRP         string // human-readable part of segwit addresses
+}
+
+var (
+	Mainnet = Network{PubKeyHashVersion: 0x00, ScriptHashVersion: 0x05, Bech32HRP: "bc"}
+	Testnet = Network{PubKeyHashVersion: 0x6f, ScriptHashVersion: 0xc4, Bech32HRP: "tb"}
+)
+
+// Address returns the address of scriptPubKey on network.
+// ErrorNoAddress is returned if scriptPubKey is not P2PKH, P2SH, or a segwit output.
+func Address(scriptPubKey []byte, network Network) (string, error) {
+	switch {
+	case len(scriptPubKey) == 25 && bytes.HasPrefix(scriptPubKey, []byte{OP_DUP, OP_HASH160, 20}) && bytes.HasSuffix(scriptPubKey, []byte{OP_EQUALVERIFY, OP_CHECKSIG}):
+		return base58CheckEncode(network.PubKeyHashVersion, scriptPubKey[3:23]), nil
+	case len(scriptPubKey) == 23 && bytes.HasPrefix(scriptPubKey, []byte{OP_HASH160, 20}) && scriptPubKey[22] == OP_EQUAL:
+		return base58CheckEncode(network.ScriptHashVersion, scriptPubKey[2:22]), nil
+	case len(scriptPubKey) >= 4 && len(scriptPubKey) <= 42 && (scriptPubKey[0] == OP_0 || (OP_1 <= scriptPubKey[0] && scriptPubKey[0] <= OP_16)) && int(scriptPubKey[1]) == len(scriptPubKey)-2:
+		version := scriptPubKey[0]
+		if version != OP_0 {
+			version -= OP_1 - 1
+		}
+		return segwitEncode(network.Bech32HRP, version, scriptPubKey[2:]), nil
+	}
+	return "", ErrorNoAddress
+}
+
+// AddressType is the type of a single-key address.
+type AddressType int
+
+const (
+	AddressP2PKH      AddressType = iota // pkh()
+	AddressP2SHP2WPKH                    // sh(wpkh())
+	AddressP2WPKH                        // wpkh()
+	AddressP2TR                          // tr() with the key path only
+)
+
+// PublicKeyAddress returns the address of type addressType for publicKey on network.
+// ErrorInvalidAddressType is returned if addressType is unknown.
+func PublicKeyAddress(publicKey secp256k1.Compressed, addressType AddressType, network Network) (string, error) {
+	var scriptPubKey []byte
+	switch addressType {
+	case AddressP2PKH:
+		scriptPubKey = p2pkhScript(publicKey[:])
+	case AddressP2SHP2WPKH:
+		scriptPubKey = p2shScript(p2wpkhScript(publicKey[:]))
+	case AddressP2WPKH:
+		scriptPubKey = p2wpkhScript(publicKey[:])
+	case AddressP2TR:
+		outputKey, err := TaprootOutputKey(publicKey, nil)
+		if err != nil {
+			return "", err
+		}
+		scriptPubKey = p2trScript(outputKey)
+	default:
+		return "", ErrorInvalidAddressType
+	}
+	return Address(scriptPubKey, network)
+}
+
+// base58CheckEncode encodes version || payload with a 4-byte checksum.
+// This function does not have a constant-time guarantee; addresses are public.
+func base58CheckEncode(version byte, payload []byte) string {
+	data := append([]byte{version}, payload...)
+	intermediate := sha256.Sum256(data)
+	checksum := sha256.Sum256(intermediate[:])
+	data = append(data, checksum[:4]...)
+	leadingZeros := 0
+	for leadingZeros < len(data) && data[leadingZeros] == 0 {
+		leadingZeros++
+	}
+	// 58^n >= 256^len(data) holds if n >= len(data) * 1.37
+	encoded := base58.VartimeEncode(data, len(data)*137/100+1)
+	encoded = strings.TrimLeft(encoded, "1")
+	return strings.Repeat("1", leadingZeros) + encoded
+}
+
+// Reference: https://github.com/bitcoin/bips/blob/master/bip-0173.mediawiki and https://github.com/bitcoin/bips/blob/master/bip-0350.mediawiki
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+const (
+	bech32Const  = 1
+	bech32mConst = 0x2bc830a3
+)
+
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, value := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(value)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	result := make([]byte, 0, 2*len(hrp)+1)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]>>5)
+	}
+	result = append(result, 0)
+	for i := 0; i < len(hrp); i++ {
+		result = append(result, hrp[i]&31)
+	}
+	return result
+}
+
+// convertBits8To5 regroups 8-bit bytes into 5-bit groups with padding.
+func convertBits8To5(data []byte) []byte {
+	var result []byte
+	acc := uint32(0)
+	bits := 0
+	for _, value := range data {
+		acc = acc<<8 | uint32(value)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			result = append(result, byte(acc>>bits)&31)
+		}
+	}
+	if bits > 0 {
+		result = append(result, byte(acc<<(5-bits))&31)
+	}
+	return result
+}
+
+// segwitEncode encodes a segwit address, using bech32 for version 0 and bech32m for the other versions.
+func segwitEncode(hrp string, version byte, program []byte) string {
+	data := append([]byte{version}, convertBits8To5(program)...)
+	constant := uint32(bech32Const)
+	if version != 0 {
+		constant = bech32mConst
+	}
+	values := append(bech32HRPExpand(hrp), data...)
+	values = append(values, 0, 0, 0, 0, 0, 0)
+	polymod := bech32Polymod(values) ^ constant
+	var builder strings.Builder
+	builder.WriteString(hrp)
+	builder.WriteByte('1')
+	for _, value := range data {
+		builder.WriteByte(bech32Charset[value])
+	}
+	for i := 0; i < 6; i++ {
+		builder.WriteByte(bech32Charset[(polymod>>(5*(5-i)))&31])
+	}
+	return builder.String()
+}
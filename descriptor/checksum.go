@@ -0,0 +1,85 @@
+package descriptor
+
+import "strings"
+
+// Reference: https://github.com/bitcoin/bips/blob/master/bip-0380.mediawiki#checksum
+const (
+	inputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+	checksumLength  = 8
+)
+
+var checksumGenerator = [5]uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd}
+
+func checksumPolymod(c uint64, value uint64) uint64 {
+	top := c >> 35
+	c = (c&0x7ffffffff)<<5 ^ value
+	for i := 0; i < len(checksumGenerator); i++ {
+		if (top>>i)&1 == 1 {
+			c ^= checksumGenerator[i]
+		}
+	}
+	return c
+}
+
+// Checksum computes the 8-character checksum of desc, which must not contain the checksum itself.
+// ErrorInvalidCharacter is returned if desc contains a character that can't appear in a descriptor.
+func Checksum(desc string) (string, error) {
+	c := uint64(1)
+	class := uint64(0)
+	classCount := 0
+	for i := 0; i < len(desc); i++ {
+		pos := strings.IndexByte(inputCharset, desc[i])
+		if pos < 0 {
+			return "", ErrorInvalidCharacter
+		}
+		c = checksumPolymod(c, uint64(pos&31))
+		class = class*3 + uint64(pos>>5)
+		classCount++
+		if classCount == 3 {
+			c = checksumPolymod(c, class)
+			class = 0
+			classCount = 0
+		}
+	}
+	if classCount > 0 {
+		c = checksumPolymod(c, class)
+	}
+	for i := 0; i < checksumLength; i++ {
+		c = checksumPolymod(c, 0)
+	}
+	c ^= 1
+	var result [checksumLength]byte
+	for i := 0; i < checksumLength; i++ {
+		result[i] = checksumCharset[(c>>(5*(7-i)))&31]
+	}
+	return string(result[:]), nil
+}
+
+// AddChecksum returns desc followed by '#' and its checksum.
+func AddChecksum(desc string) (string, error) {
+	checksum, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	return desc + "#" + checksum, nil
+}
+
+// StripChecksum removes the checksum from s after verifying it. s may have no checksum.
+func StripChecksum(s string) (string, error) {
+	desc, checksum, found := strings.Cut(s, "#")
+	expected, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return desc, nil
+	}
+	if len(checksum) != checksumLength {
+		return "", ErrorInvalidChecksum
+	}
+	if checksum != expected {
+		return "", ErrorChecksumMismatch
+	}
+	return desc, nil
+}
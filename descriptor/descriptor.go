@@ -0,0 +1,421 @@
+// Package descriptor provides output script descriptors (BIP 380-386) over BIP 32 extended public keys.
+//
+// Supported descriptors are pk, pkh, wpkh, sh, wsh, tr (key path only), multi, sortedmulti and combo,
+// with key origins, ranged keys (*) and multipath keys (<0;1>, BIP 389).
+// Only public keys are supported.
+//
+// Spec: https://github.com/bitcoin/bips/blob/master/bip-0380.mediawiki
+package descriptor
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+)
+
+var (
+	ErrorInvalidCharacter       = errors.New("invalid character in descriptor")
+	ErrorInvalidChecksum        = errors.New("checksum is invalid")
+	ErrorChecksumMismatch       = errors.New("checksum mismatch")
+	ErrorInvalidDescriptor      = errors.New("descriptor is invalid")
+	ErrorInvalidKey             = errors.New("key expression is invalid")
+	ErrorInvalidKeyOrigin       = errors.New("key origin is invalid")
+	ErrorInvalidDerivationPath  = errors.New("derivation path is invalid")
+	ErrorHardenedDerivation     = errors.New("can't derive a hardened child key from an extended public key")
+	ErrorPrivateKeyNotSupported = errors.New("private keys are not supported")
+	ErrorNotExtendedKey         = errors.New("key is not an extended key")
+	ErrorInvalidThreshold       = errors.New("threshold of multi is invalid")
+	ErrorTooManyKeys            = errors.New("too many keys in multi")
+	ErrorMultipath              = errors.New("descriptor has multipath keys; use Descriptors to split it")
+	ErrorMultipathLength        = errors.New("multipath keys have different numbers of alternatives")
+	ErrorNoAddress              = errors.New("output has no address")
+	ErrorInvalidAddressType     = errors.New("address type is invalid")
+)
+
+type kind int
+
+const (
+	kindPk kind = iota
+	kindPkh
+	kindWpkh
+	kindSh
+	kindWsh
+	kindTr
+	kindMulti
+	kindSortedMulti
+	kindCombo
+)
+
+var kindNames = map[kind]string{
+	kindPk:          "pk",
+	kindPkh:         "pkh",
+	kindWpkh:        "wpkh",
+	kindSh:          "sh",
+	kindWsh:         "wsh",
+	kindTr:          "tr",
+	kindMulti:       "multi",
+	kindSortedMulti: "sortedmulti",
+	kindCombo:       "combo",
+}
+
+type context int
+
+const (
+	contextTop context = iota
+	contextSh
+	contextWsh
+)
+
+// maximum numbers of keys in multi in each context
+var multiLimits = map[context]int{
+	contextTop: 3,
+	contextSh:  15,
+	contextWsh: 20,
+}
+
+// Descriptor is a parsed output script descriptor.
+type Descriptor struct {
+	kind      kind
+	keys      []*Key
+	threshold int         // for multi and sortedmulti
+	sub       *Descriptor // for sh and wsh
+}
+
+// Output is an output described by a Descriptor at an index.
+type Output struct {
+	ScriptPubKey  []byte
+	RedeemScript  []byte // non-nil for sh()
+	WitnessScript []byte // non-nil for wsh()
+}
+
+// Parse parses a descriptor. If s has a checksum, it is verified.
+func Parse(s string) (*Descriptor, error) {
+	desc, err := StripChecksum(s)
+	if err != nil {
+		return nil, err
+	}
+	d, err := parse(desc, contextTop)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.multipathLen(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func parse(s string, ctx context) (*Descriptor, error) {
+	name, args, err := splitCall(s)
+	if err != nil {
+		return nil, err
+	}
+	d := Descriptor{}
+	switch name {
+	case "pk", "pkh", "combo", "wpkh", "tr":
+		kinds := map[string]kind{"pk": kindPk, "pkh": kindPkh, "combo": kindCombo, "wpkh": kindWpkh, "tr": kindTr}
+		d.kind = kinds[name]
+		if len(args) != 1 ||
+			(d.kind == kindCombo && ctx != contextTop) ||
+			(d.kind == kindTr && ctx != contextTop) ||
+			(d.kind == kindWpkh && ctx == contextWsh) {
+			return nil, ErrorInvalidDescriptor
+		}
+		key, err := ParseKey(args[0])
+		if err != nil {
+			return nil, err
+		}
+		if key.xOnly && d.kind != kindTr {
+			return nil, ErrorInvalidKey
+		}
+		d.keys = []*Key{key}
+	case "sh", "wsh":
+		if len(args) != 1 || ctx == contextWsh || (name == "sh" && ctx != contextTop) {
+			return nil, ErrorInvalidDescriptor
+		}
+		d.kind = kindSh
+		subCtx := contextSh
+		if name == "wsh" {
+			d.kind = kindWsh
+			subCtx = contextWsh
+		}
+		sub, err := parse(args[0], subCtx)
+		if err != nil {
+			return nil, err
+		}
+		d.sub = sub
+	case "multi", "sortedmulti":
+		d.kind = kindMulti
+		if name == "sortedmulti" {
+			d.kind = kindSortedMulti
+		}
+		if len(args) < 2 {
+			return nil, ErrorInvalidDescriptor
+		}
+		threshold, err := strconv.Atoi(args[0])
+		if err != nil || threshold < 1 || threshold > len(args)-1 {
+			return nil, ErrorInvalidThreshold
+		}
+		if len(args)-1 > multiLimits[ctx] {
+			return nil, ErrorTooManyKeys
+		}
+		d.threshold = threshold
+		for _, arg := range args[1:] {
+			key, err := ParseKey(arg)
+			if err != nil {
+				return nil, err
+			}
+			if key.xOnly {
+				return nil, ErrorInvalidKey
+			}
+			d.keys = append(d.keys, key)
+		}
+	default:
+		return nil, ErrorInvalidDescriptor
+	}
+	return &d, nil
+}
+
+// splitCall splits "name(arg1,arg2,...)" into the name and the top-level arguments.
+func splitCall(s string) (string, []string, error) {
+	open := strings.IndexByte(s, '(')
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return "", nil, ErrorInvalidDescriptor
+	}
+	args, err := SplitArgs(s[open+1 : len(s)-1])
+	if err != nil {
+		return "", nil, err
+	}
+	return s[:open], args, nil
+}
+
+// SplitArgs splits a comma-separated argument list at the top level, ignoring commas inside (), [], {} and <>.
+func SplitArgs(s string) ([]string, error) {
+	var args []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(', '[', '{', '<':
+			depth++
+		case ')', ']', '}', '>':
+			depth--
+			if depth < 0 {
+				return nil, ErrorInvalidDescriptor
+			}
+		case ',':
+			if depth == 0 {
+				args = append(args, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, ErrorInvalidDescriptor
+	}
+	return append(args, s[start:]), nil
+}
+
+// Keys returns all KEY expressions in this Descriptor.
+func (d *Descriptor) Keys() []*Key {
+	if d.sub != nil {
+		return d.sub.Keys()
+	}
+	return append([]*Key(nil), d.keys...)
+}
+
+// IsRange returns true if this Descriptor has a ranged key, that is, it describes different outputs at different indices.
+func (d *Descriptor) IsRange() bool {
+	for _, key := range d.Keys() {
+		if key.IsRange() {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Descriptor) multipathLen() (int, error) {
+	result := 1
+	for _, key := range d.Keys() {
+		if n := key.MultipathLen(); n > 1 {
+			if result > 1 && result != n {
+				return 0, ErrorMultipathLength
+			}
+			result = n
+		}
+	}
+	return result, nil
+}
+
+// Descriptors splits this Descriptor with multipath keys (BIP 389) into single-path descriptors.
+// If this Descriptor has no multipath keys, it returns a slice with only this Descriptor.
+func (d *Descriptor) Descriptors() []*Descriptor {
+	// multipathLen was checked in Parse
+	n, _ := d.multipathLen()
+	result := make([]*Descriptor, n)
+	for i := 0; i < n; i++ {
+		result[i] = d.singlePath(i)
+	}
+	return result
+}
+
+func (d *Descriptor) singlePath(i int) *Descriptor {
+	result := *d
+	if d.sub != nil {
+		result.sub = d.sub.singlePath(i)
+	}
+	result.keys = make([]*Key, len(d.keys))
+	for j, key := range d.keys {
+		result.keys[j] = key.SinglePath(i)
+	}
+	return &result
+}
+
+// String returns the descriptor with its checksum.
+func (d *Descriptor) String() string {
+	desc := d.string()
+	// desc consists of valid characters only
+	checksum, _ := Checksum(desc)
+	return desc + "#" + checksum
+}
+
+func (d *Descriptor) string() string {
+	var builder strings.Builder
+	builder.WriteString(kindNames[d.kind])
+	builder.WriteByte('(')
+	switch {
+	case d.sub != nil:
+		builder.WriteString(d.sub.string())
+	case d.kind == kindMulti || d.kind == kindSortedMulti:
+		builder.WriteString(strconv.Itoa(d.threshold))
+		for _, key := range d.keys {
+			builder.WriteByte(',')
+			builder.WriteString(key.String())
+		}
+	default:
+		builder.WriteString(d.keys[0].String())
+	}
+	builder.WriteByte(')')
+	return builder.String()
+}
+
+// Expand returns the outputs described by this Descriptor at index. The index is ignored if this Descriptor is not ranged.
+// combo() yields P2PK, P2PKH, P2WPKH and P2SH-P2WPKH outputs in this order; the other descriptors yield exactly one output.
+// ErrorMultipath is returned if this Descriptor has multipath keys.
+func (d *Descriptor) Expand(index uint32) ([]Output, error) {
+	if n, _ := d.multipathLen(); n > 1 {
+		return nil, ErrorMultipath
+	}
+	switch d.kind {
+	case kindSh:
+		inner, err := d.sub.Expand(index)
+		if err != nil {
+			return nil, err
+		}
+		redeemScript := inner[0].ScriptPubKey
+		return []Output{{
+			ScriptPubKey:  p2shScript(redeemScript),
+			RedeemScript:  redeemScript,
+			WitnessScript: inner[0].WitnessScript,
+		}}, nil
+	case kindWsh:
+		inner, err := d.sub.Expand(index)
+		if err != nil {
+			return nil, err
+		}
+		witnessScript := inner[0].ScriptPubKey
+		return []Output{{
+			ScriptPubKey:  p2wshScript(witnessScript),
+			WitnessScript: witnessScript,
+		}}, nil
+	case kindMulti, kindSortedMulti:
+		publicKeys := make([][]byte, len(d.keys))
+		for i, key := range d.keys {
+			publicKey, err := key.PublicKey(index)
+			if err != nil {
+				return nil, err
+			}
+			publicKeys[i] = publicKey[:]
+		}
+		if d.kind == kindSortedMulti {
+			sort.Slice(publicKeys, func(i, j int) bool {
+				return bytes.Compare(publicKeys[i], publicKeys[j]) < 0
+			})
+		}
+		return []Output{{ScriptPubKey: multiScript(d.threshold, publicKeys)}}, nil
+	}
+	publicKey, err := d.keys[0].PublicKey(index)
+	if err != nil {
+		return nil, err
+	}
+	switch d.kind {
+	case kindPk:
+		return []Output{{ScriptPubKey: p2pkScript(publicKey[:])}}, nil
+	case kindPkh:
+		return []Output{{ScriptPubKey: p2pkhScript(publicKey[:])}}, nil
+	case kindWpkh:
+		return []Output{{ScriptPubKey: p2wpkhScript(publicKey[:])}}, nil
+	case kindTr:
+		outputKey, err := TaprootOutputKey(publicKey, nil)
+		if err != nil {
+			return nil, err
+		}
+		return []Output{{ScriptPubKey: p2trScript(outputKey)}}, nil
+	case kindCombo:
+		redeemScript := p2wpkhScript(publicKey[:])
+		return []Output{
+			{ScriptPubKey: p2pkScript(publicKey[:])},
+			{ScriptPubKey: p2pkhScript(publicKey[:])},
+			{ScriptPubKey: redeemScript},
+			{ScriptPubKey: p2shScript(redeemScript), RedeemScript: redeemScript},
+		}, nil
+	}
+	return nil, ErrorInvalidDescriptor
+}
+
+// Addresses returns the addresses of the outputs described by this Descriptor at index on network.
+// Outputs without addresses (P2PK in combo()) are skipped.
+// ErrorNoAddress is returned if no output has an address (bare pk() and multi()).
+func (d *Descriptor) Addresses(index uint32, network Network) ([]string, error) {
+	outputs, err := d.Expand(index)
+	if err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, output := range outputs {
+		address, err := Address(output.ScriptPubKey, network)
+		if err != nil {
+			continue
+		}
+		result = append(result, address)
+	}
+	if len(result) == 0 {
+		return nil, ErrorNoAddress
+	}
+	return result, nil
+}
+
+// TaprootOutputKey computes the x-only output key Q = P + int(hash_TapTweak(P || merkleRoot)) G (BIP 341),
+// where P is internalKey with its y coordinate made even. If merkleRoot is nil, the output has no script path.
+func TaprootOutputKey(internalKey secp256k1.Compressed, merkleRoot []byte) ([32]byte, error) {
+	internalKey[0] = 0x02
+	p, err := internalKey.Uncompress()
+	if err != nil {
+		return [32]byte{}, err
+	}
+	tweak := taggedHash("TapTweak", internalKey[1:], merkleRoot)
+	if secp256k1.SCIsValid(tweak) != 1 {
+		return [32]byte{}, ErrorInvalidKey
+	}
+	var tweakPoint, q secp256k1.Point
+	tweakPoint.GEPoint(tweak)
+	q.GEAdd(p, &tweakPoint)
+	if q.IsInfinity() == 1 {
+		return [32]byte{}, ErrorInvalidKey
+	}
+	compressed := q.Compress()
+	return [32]byte(compressed[1:]), nil
+}
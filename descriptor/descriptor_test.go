@@ -0,0 +1,231 @@
+package descriptor
+
+import (
+	"encoding/hex"
+	"testing"
+
+	bip32 "github.com/koba-e964/bip32-typesafe"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+	"github.com/stretchr/testify/assert"
+)
+
+// account-level keys derived from the seed of "abandon abandon ... about", used in BIP 44, 49, 84 and 86
+const (
+	bip44Xpub = "xpub6BosfCnifzxcFwrSzQiqu2DBVTshkCXacvNsWGYJVVhhawA7d4R5WSWGFNbi8Aw6ZRc1brxMyWMzG3DSSSSoekkudhUd9yLb6qx39T9nMdj"
+	bip49Xpub = "xpub6CkG15Jdw866GKs84e7ysjxAhBQUJBdLZTVbQERCjwh2z6wZSSdjfmaXaMvf6Vm5sbWemK43d7HJMicz41G3vEHA9Sa5N2J9j9vgwyiHdMj"
+	bip84Xpub = "xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V"
+	bip86Xpub = "xpub6BgBgsespWvERF3LHQu6CnqdvfEvtMcQjYrcRzx53QJjSxarj2afYWcLteoGVky7D3UKDP9QyrLprQ3VCECoY49yfdDEHGCtMMj92pReUsQ"
+)
+
+func TestChecksum(t *testing.T) {
+	tests := []struct {
+		desc     string
+		checksum string
+	}{
+		// https://github.com/bitcoin/bips/blob/master/bip-0380.mediawiki#test-vectors
+		{desc: "raw(deadbeef)", checksum: "89f8spxm"},
+		// https://github.com/bitcoin/bitcoin/blob/master/doc/descriptors.md
+		{desc: "pkh([d6043800/0'/0'/18']03efdee34c0009fd175f3b20b5e5a5517fd5d16746f2e635b44617adafeaebc388)", checksum: "4ahsl9pk"},
+	}
+	for _, test := range tests {
+		checksum, err := Checksum(test.desc)
+		assert.Nil(t, err)
+		assert.Equal(t, test.checksum, checksum)
+		withChecksum, err := AddChecksum(test.desc)
+		assert.Nil(t, err)
+		assert.Equal(t, test.desc+"#"+test.checksum, withChecksum)
+	}
+	_, err := Checksum("raw(deadbeef\n)")
+	assert.Equal(t, ErrorInvalidCharacter, err)
+}
+
+func TestAddresses(t *testing.T) {
+	tests := []struct {
+		desc      string
+		index     uint32
+		network   Network
+		addresses []string
+	}{
+		// BIP 44 has no test vectors; this is the widely used address at m/44'/0'/0'/0/0
+		{desc: "pkh([73c5da0a/44'/0'/0']" + bip44Xpub + "/0/*)", index: 0, network: Mainnet, addresses: []string{"1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"}},
+		// https://github.com/bitcoin/bips/blob/master/bip-0049.mediawiki#test-vectors
+		{desc: "sh(wpkh([73c5da0a/49'/1'/0']" + bip49Xpub + "/0/*))", index: 0, network: Testnet, addresses: []string{"2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2"}},
+		// https://github.com/bitcoin/bips/blob/master/bip-0084.mediawiki#test-vectors
+		{desc: "wpkh([73c5da0a/84'/0'/0']" + bip84Xpub + "/0/*)", index: 0, network: Mainnet, addresses: []string{"bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"}},
+		{desc: "wpkh([73c5da0a/84'/0'/0']" + bip84Xpub + "/0/*)", index: 1, network: Mainnet, addresses: []string{"bc1qnjg0jd8228aq7egyzacy8cys3knf9xvrerkf9g"}},
+		{desc: "wpkh([73c5da0a/84'/0'/0']" + bip84Xpub + "/1/*)", index: 0, network: Mainnet, addresses: []string{"bc1q8c6fshw2dlwun7ekn9qwf37cu2rn755upcp6el"}},
+		// https://github.com/bitcoin/bips/blob/master/bip-0086.mediawiki#test-vectors
+		{desc: "tr([73c5da0a/86'/0'/0']" + bip86Xpub + "/0/*)", index: 0, network: Mainnet, addresses: []string{"bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"}},
+		{desc: "tr([73c5da0a/86'/0'/0']" + bip86Xpub + "/0/*)", index: 1, network: Mainnet, addresses: []string{"bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh"}},
+		{desc: "tr([73c5da0a/86'/0'/0']" + bip86Xpub + "/1/*)", index: 0, network: Mainnet, addresses: []string{"bc1p3qkhfews2uk44qtvauqyr2ttdsw7svhkl9nkm9s9c3x4ax5h60wqwruhk7"}},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.desc)
+		assert.Nil(t, err, test.desc)
+		addresses, err := d.Addresses(test.index, test.network)
+		assert.Nil(t, err, test.desc)
+		assert.Equal(t, test.addresses, addresses, test.desc)
+	}
+}
+
+func TestAddressesMatchDerivation(t *testing.T) {
+	seed, _ := hex.DecodeString("5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4")
+	account := bip32.NewMasterKey(seed)
+	for _, childIdx := range []uint32{84, 0, 0} {
+		var err error
+		account, err = account.NewChildKey(bip32.FirstHardenedChildIndex + childIdx)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, bip84Xpub, account.GetPublicKey().B58Serialize())
+	d, err := Parse("wpkh([" + account.KeyOrigin().String() + "]" + bip84Xpub + "/0/*)")
+	assert.Nil(t, err)
+	key, err := d.Keys()[0].ExtendedPublicKey(7)
+	assert.Nil(t, err)
+	assert.Equal(t, "73c5da0a/84'/0'/0'/0/7", key.KeyOrigin().String())
+	external, _ := account.NewChildKey(0)
+	expected, _ := external.NewChildKey(7)
+	assert.Equal(t, expected.GetPublicKey(), key)
+}
+
+func TestPublicKeyAddress(t *testing.T) {
+	tests := []struct {
+		xpub        string
+		addressType AddressType
+		network     Network
+		address     string
+	}{
+		{xpub: bip44Xpub, addressType: AddressP2PKH, network: Mainnet, address: "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		{xpub: bip49Xpub, addressType: AddressP2SHP2WPKH, network: Testnet, address: "2Mww8dCYPUpKHofjgcXcBCEGmniw9CoaiD2"},
+		{xpub: bip84Xpub, addressType: AddressP2WPKH, network: Mainnet, address: "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{xpub: bip86Xpub, addressType: AddressP2TR, network: Mainnet, address: "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+	}
+	for _, test := range tests {
+		key, err := ParseKey(test.xpub + "/0/0")
+		assert.Nil(t, err)
+		publicKey, err := key.PublicKey(0)
+		assert.Nil(t, err)
+		address, err := PublicKeyAddress(publicKey, test.addressType, test.network)
+		assert.Nil(t, err)
+		assert.Equal(t, test.address, address)
+	}
+	_, err := PublicKeyAddress(secp256k1.Compressed{}, AddressType(-1), Mainnet)
+	assert.Equal(t, ErrorInvalidAddressType, err)
+}
+
+func TestMultipath(t *testing.T) {
+	d, err := Parse("wpkh([73c5da0a/84'/0'/0']" + bip84Xpub + "/<0;1>/*)")
+	assert.Nil(t, err)
+	assert.True(t, d.IsRange())
+	_, err = d.Expand(0)
+	assert.Equal(t, ErrorMultipath, err)
+	descriptors := d.Descriptors()
+	assert.Equal(t, 2, len(descriptors))
+	external, err := Parse("wpkh([73c5da0a/84'/0'/0']" + bip84Xpub + "/0/*)")
+	assert.Nil(t, err)
+	change, err := Parse("wpkh([73c5da0a/84'/0'/0']" + bip84Xpub + "/1/*)")
+	assert.Nil(t, err)
+	assert.Equal(t, external.String(), descriptors[0].String())
+	assert.Equal(t, change.String(), descriptors[1].String())
+
+	_, err = Parse("wsh(multi(1," + bip84Xpub + "/<0;1>/*," + bip86Xpub + "/<0;1;2>/*))")
+	assert.Equal(t, ErrorMultipathLength, err)
+}
+
+func TestSortedMulti(t *testing.T) {
+	key1 := "03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd"
+	key2 := "022f8bde4d1a07209355b4a7250a5c5128e88b84bddc619ab7cba8d569b240efe4" // 5G
+	sorted1, err := Parse("wsh(sortedmulti(1," + key1 + "," + key2 + "))")
+	assert.Nil(t, err)
+	sorted2, err := Parse("wsh(sortedmulti(1," + key2 + "," + key1 + "))")
+	assert.Nil(t, err)
+	unsorted, err := Parse("wsh(multi(1," + key1 + "," + key2 + "))")
+	assert.Nil(t, err)
+	sorted, err := Parse("wsh(multi(1," + key2 + "," + key1 + "))")
+	assert.Nil(t, err)
+	outputs1, _ := sorted1.Expand(0)
+	outputs2, _ := sorted2.Expand(0)
+	outputsUnsorted, _ := unsorted.Expand(0)
+	outputsSorted, _ := sorted.Expand(0)
+	assert.Equal(t, outputs1, outputs2)
+	assert.Equal(t, outputsSorted, outputs1)
+	assert.NotEqual(t, outputsUnsorted, outputs1)
+	assert.Equal(t, "5121"+key2+"21"+key1+"52ae", hex.EncodeToString(outputs1[0].WitnessScript))
+}
+
+func TestCombo(t *testing.T) {
+	key := "03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd"
+	d, err := Parse("combo(" + key + ")")
+	assert.Nil(t, err)
+	outputs, err := d.Expand(0)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(outputs))
+	assert.Equal(t, "21"+key+"ac", hex.EncodeToString(outputs[0].ScriptPubKey))
+	addresses, err := d.Addresses(0, Mainnet)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(addresses))
+
+	pk, err := Parse("pk(" + key + ")")
+	assert.Nil(t, err)
+	_, err = pk.Addresses(0, Mainnet)
+	assert.Equal(t, ErrorNoAddress, err)
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, desc := range []string{
+		"pkh([73c5da0a/44'/0'/0']" + bip44Xpub + "/0/*)",
+		"sh(wsh(sortedmulti(2," + bip44Xpub + "/0/*,[73c5da0a/84'/0'/0']" + bip84Xpub + "/<0;1>/*)))",
+		"tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+	} {
+		d, err := Parse(desc)
+		assert.Nil(t, err, desc)
+		withChecksum, err := AddChecksum(desc)
+		assert.Nil(t, err)
+		assert.Equal(t, withChecksum, d.String())
+		reparsed, err := Parse(d.String())
+		assert.Nil(t, err)
+		assert.Equal(t, d, reparsed)
+	}
+	// 'h' is accepted as a hardened marker
+	d, err := Parse("pkh([73c5da0a/44h/0h/0h]" + bip44Xpub + "/0/*)")
+	assert.Nil(t, err)
+	assert.Equal(t, "pkh([73c5da0a/44'/0'/0']"+bip44Xpub+"/0/*)#8w4z8fed", d.String())
+}
+
+func TestParseFailure(t *testing.T) {
+	key := "03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd"
+	tests := []struct {
+		desc        string
+		expectedErr error
+	}{
+		{desc: "pkh(" + key + ")#4ahsl9pk", expectedErr: ErrorChecksumMismatch},
+		{desc: "pkh(" + key + ")#4ahsl9p", expectedErr: ErrorInvalidChecksum},
+		{desc: "pkh(" + key + ",)", expectedErr: ErrorInvalidDescriptor},
+		{desc: "wsh(wpkh(" + key + "))", expectedErr: ErrorInvalidDescriptor},
+		{desc: "sh(sh(pkh(" + key + ")))", expectedErr: ErrorInvalidDescriptor},
+		{desc: "wsh(wsh(pkh(" + key + ")))", expectedErr: ErrorInvalidDescriptor},
+		{desc: "sh(tr(" + key + "))", expectedErr: ErrorInvalidDescriptor},
+		{desc: "sh(combo(" + key + "))", expectedErr: ErrorInvalidDescriptor},
+		{desc: "foo(" + key + ")", expectedErr: ErrorInvalidDescriptor},
+		{desc: "pkh(" + key[2:] + ")", expectedErr: ErrorInvalidKey},
+		{desc: "pkh(04" + key[2:] + ")", expectedErr: ErrorInvalidKey},
+		{desc: "pkh([d34db33f/0x]" + key + ")", expectedErr: ErrorInvalidDerivationPath},
+		{desc: "pkh([d34db3/0]" + key + ")", expectedErr: ErrorInvalidKeyOrigin},
+		{desc: "pkh([73c5da0a/44'/0']" + bip44Xpub + "/0/*)", expectedErr: bip32.ErrorKeyOriginMismatch},
+		{desc: "pkh([73c5da0a/44'/0'/1']" + bip44Xpub + "/0/*)", expectedErr: bip32.ErrorKeyOriginMismatch},
+		{desc: "pkh(" + bip44Xpub + "/0'/*)", expectedErr: ErrorHardenedDerivation},
+		{desc: "pkh(" + bip44Xpub + "/*')", expectedErr: ErrorHardenedDerivation},
+		{desc: "pkh(" + bip44Xpub + "/*/0)", expectedErr: ErrorInvalidDerivationPath},
+		{desc: "pkh(" + bip44Xpub + "/<0;1>/<0;1>)", expectedErr: ErrorInvalidDerivationPath},
+		{desc: "pkh(" + bip44Xpub + "/2147483648)", expectedErr: ErrorInvalidDerivationPath},
+		{desc: "pkh(xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi)", expectedErr: ErrorPrivateKeyNotSupported},
+		{desc: "pkh(xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet9)", expectedErr: bip32.ErrorChecksumMismatch},
+		{desc: "wsh(multi(0," + key + "))", expectedErr: ErrorInvalidThreshold},
+		{desc: "wsh(multi(2," + key + "))", expectedErr: ErrorInvalidThreshold},
+		{desc: "multi(1," + key + "," + key + "," + key + "," + key + ")", expectedErr: ErrorTooManyKeys},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.desc)
+		assert.Nil(t, d, test.desc)
+		assert.ErrorIs(t, err, test.expectedErr, test.desc)
+	}
+}
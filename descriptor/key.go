@@ -0,0 +1,283 @@
+package descriptor
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	bip32 "github.com/koba-e964/bip32-typesafe"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+)
+
+// Key is a KEY expression in a descriptor: an optional key origin followed by
+// either a hex-encoded public key or an extended public key with a derivation path.
+//
+// Only public keys are supported; extended private keys and hardened derivation after an extended public key are rejected.
+type Key struct {
+	origin *bip32.KeyOrigin // nil if not given
+
+	// either extended or raw is set
+	extended *bip32.PublicKey
+	raw      secp256k1.Compressed
+	xOnly    bool // raw was given as a 32-byte x-only public key
+
+	path      []uint32 // derivation steps after extended, excluding the wildcard
+	multipath []uint32 // alternatives for the step at multipathPos, or nil if this is not a multipath key
+	multiPos  int
+	wildcard  bool
+}
+
+// ParseKey parses a KEY expression such as "[d34db33f/84'/0'/0']xpub.../0/*".
+// bip32.ErrorKeyOriginMismatch is returned if the key origin of an extended public key is inconsistent with its depth or child number.
+func ParseKey(s string) (*Key, error) {
+	k := Key{}
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return nil, ErrorInvalidKeyOrigin
+		}
+		origin, err := parseKeyOrigin(s[1:end])
+		if err != nil {
+			return nil, err
+		}
+		k.origin = origin
+		s = s[end+1:]
+	}
+	elements := strings.Split(s, "/")
+	if len(elements[0]) == 66 || len(elements[0]) == 64 {
+		if len(elements) != 1 {
+			return nil, ErrorInvalidKey
+		}
+		data, err := hex.DecodeString(elements[0])
+		if err != nil {
+			return nil, ErrorInvalidKey
+		}
+		if len(data) == 32 {
+			k.xOnly = true
+			data = append([]byte{0x02}, data...)
+		}
+		k.raw = secp256k1.Compressed(data)
+		if _, err := k.raw.Uncompress(); err != nil {
+			return nil, ErrorInvalidKey
+		}
+		return &k, nil
+	}
+	extended, err := bip32.B58DeserializePublicKey(elements[0])
+	if err != nil {
+		if _, errPrv := bip32.B58DeserializePrivateKey(elements[0]); errPrv == nil {
+			return nil, ErrorPrivateKeyNotSupported
+		}
+		return nil, err
+	}
+	if k.origin != nil {
+		// keys derived from extended inherit the origin, so it must be consistent with extended
+		if extended, err = extended.WithKeyOrigin(*k.origin); err != nil {
+			return nil, err
+		}
+	}
+	k.extended = extended
+	for i, element := range elements[1:] {
+		last := i == len(elements)-2
+		switch {
+		case element == "*":
+			if !last {
+				return nil, ErrorInvalidDerivationPath
+			}
+			k.wildcard = true
+		case strings.HasPrefix(element, "<") && strings.HasSuffix(element, ">"):
+			if k.multipath != nil {
+				return nil, ErrorInvalidDerivationPath
+			}
+			alternatives := strings.Split(element[1:len(element)-1], ";")
+			if len(alternatives) < 2 {
+				return nil, ErrorInvalidDerivationPath
+			}
+			for _, alternative := range alternatives {
+				childIdx, err := parseChildIndex(alternative, false)
+				if err != nil {
+					return nil, err
+				}
+				k.multipath = append(k.multipath, childIdx)
+			}
+			k.multiPos = len(k.path)
+			k.path = append(k.path, k.multipath[0])
+		default:
+			childIdx, err := parseChildIndex(element, false)
+			if err != nil {
+				return nil, err
+			}
+			k.path = append(k.path, childIdx)
+		}
+	}
+	return &k, nil
+}
+
+// parseKeyOrigin parses a key origin without brackets, such as "d34db33f/84'/0'/0'".
+func parseKeyOrigin(s string) (*bip32.KeyOrigin, error) {
+	elements := strings.Split(s, "/")
+	fingerprint, err := hex.DecodeString(elements[0])
+	if err != nil || len(fingerprint) != 4 {
+		return nil, ErrorInvalidKeyOrigin
+	}
+	origin := bip32.KeyOrigin{MasterFingerprint: [4]byte(fingerprint)}
+	for _, element := range elements[1:] {
+		childIdx, err := parseChildIndex(element, true)
+		if err != nil {
+			return nil, err
+		}
+		origin.Path = append(origin.Path, childIdx)
+	}
+	return &origin, nil
+}
+
+// parseChildIndex parses an element of a derivation path such as "0", "84'" or "84h".
+func parseChildIndex(s string, allowHardened bool) (uint32, error) {
+	hardened := strings.HasSuffix(s, "'") || strings.HasSuffix(s, "h") || strings.HasSuffix(s, "H")
+	if hardened {
+		if !allowHardened {
+			return 0, ErrorHardenedDerivation
+		}
+		s = s[:len(s)-1]
+	}
+	// ParseUint accepts only digits here because of the base 10, but we reject a leading '+' explicitly
+	if s == "" || s[0] < '0' || s[0] > '9' {
+		return 0, ErrorInvalidDerivationPath
+	}
+	value, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || uint32(value) >= bip32.FirstHardenedChildIndex {
+		return 0, ErrorInvalidDerivationPath
+	}
+	if hardened {
+		return uint32(value) + bip32.FirstHardenedChildIndex, nil
+	}
+	return uint32(value), nil
+}
+
+// KeyOrigin returns the key origin given in this Key, or nil if it is not given.
+func (k *Key) KeyOrigin() *bip32.KeyOrigin {
+	if k.origin == nil {
+		return nil
+	}
+	result := *k.origin
+	result.Path = append([]uint32(nil), k.origin.Path...)
+	return &result
+}
+
+// IsXOnly returns true if this Key was given as a 32-byte x-only public key, which is allowed only in tr().
+func (k *Key) IsXOnly() bool {
+	return k.xOnly
+}
+
+// IsRange returns true if this Key ends with a wildcard (*).
+func (k *Key) IsRange() bool {
+	return k.wildcard
+}
+
+// MultipathLen returns the number of alternatives in the multipath element (such as <0;1>) of this Key, or 1 if there is none.
+func (k *Key) MultipathLen() int {
+	if k.multipath == nil {
+		return 1
+	}
+	return len(k.multipath)
+}
+
+// SinglePath returns the Key with the multipath element replaced by its i-th alternative.
+// If this Key is not a multipath key, it returns this Key itself.
+func (k *Key) SinglePath(i int) *Key {
+	if k.multipath == nil {
+		return k
+	}
+	result := *k
+	result.path = append([]uint32(nil), k.path...)
+	result.path[k.multiPos] = k.multipath[i]
+	result.multipath = nil
+	return &result
+}
+
+// PublicKey returns the public key at index (which is used only if this Key is ranged).
+// If this Key has a multipath element, the first alternative is used.
+func (k *Key) PublicKey(index uint32) (secp256k1.Compressed, error) {
+	derived, err := k.derive(index)
+	if err != nil {
+		return secp256k1.Compressed{}, err
+	}
+	if derived == nil {
+		return k.raw, nil
+	}
+	return derived.PublicKey(), nil
+}
+
+// ExtendedPublicKey returns the extended public key at index (which is used only if this Key is ranged).
+// The returned key has its origin if the origin of this Key is given.
+// ErrorNotExtendedKey is returned if this Key is not an extended key.
+func (k *Key) ExtendedPublicKey(index uint32) (*bip32.PublicKey, error) {
+	derived, err := k.derive(index)
+	if err != nil {
+		return nil, err
+	}
+	if derived == nil {
+		return nil, ErrorNotExtendedKey
+	}
+	return derived, nil
+}
+
+// derive returns the derived extended public key, or nil if this Key is not an extended key.
+func (k *Key) derive(index uint32) (*bip32.PublicKey, error) {
+	if k.extended == nil {
+		return nil, nil
+	}
+	current := k.extended
+	path := k.path
+	if k.wildcard {
+		if index >= bip32.FirstHardenedChildIndex {
+			return nil, ErrorHardenedDerivation
+		}
+		path = append(path[:len(path):len(path)], index)
+	}
+	for _, childIdx := range path {
+		child, err := current.NewChildKey(childIdx)
+		if err != nil {
+			return nil, err
+		}
+		current = child
+	}
+	return current, nil
+}
+
+// String returns the KEY expression.
+func (k *Key) String() string {
+	var builder strings.Builder
+	if k.origin != nil {
+		builder.WriteByte('[')
+		builder.WriteString(k.origin.String())
+		builder.WriteByte(']')
+	}
+	if k.extended == nil {
+		if k.xOnly {
+			builder.WriteString(hex.EncodeToString(k.raw[1:]))
+		} else {
+			builder.WriteString(hex.EncodeToString(k.raw[:]))
+		}
+		return builder.String()
+	}
+	builder.WriteString(k.extended.B58Serialize())
+	for i, childIdx := range k.path {
+		builder.WriteByte('/')
+		if k.multipath != nil && i == k.multiPos {
+			builder.WriteByte('<')
+			for j, alternative := range k.multipath {
+				if j > 0 {
+					builder.WriteByte(';')
+				}
+				builder.WriteString(strconv.FormatUint(uint64(alternative), 10))
+			}
+			builder.WriteByte('>')
+			continue
+		}
+		builder.WriteString(strconv.FormatUint(uint64(childIdx), 10))
+	}
+	if k.wildcard {
+		builder.WriteString("/*")
+	}
+	return builder.String()
+}
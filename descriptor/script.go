@@ -0,0 +1,147 @@
+package descriptor
+
+import (
+	"crypto/sha256"
+
+	//lint:ignore SA1019 we want to implement a bitcoin-oriented package, so using RIPEMD-160 is inevitable.
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Opcodes used in scripts generated by this package.
+const (
+	OP_0             = 0x00
+	OP_PUSHDATA1     = 0x4c
+	OP_PUSHDATA2     = 0x4d
+	OP_1NEGATE       = 0x4f
+	OP_1             = 0x51
+	OP_16            = 0x60
+	OP_DUP           = 0x76
+	OP_EQUAL         = 0x87
+	OP_EQUALVERIFY   = 0x88
+	OP_HASH160       = 0xa9
+	OP_CHECKSIG      = 0xac
+	OP_CHECKMULTISIG = 0xae
+)
+
+// ScriptBuilder builds a script by appending opcodes and pushes.
+type ScriptBuilder struct {
+	script []byte
+}
+
+// AddOp appends opcodes.
+func (b *ScriptBuilder) AddOp(ops ...byte) *ScriptBuilder {
+	b.script = append(b.script, ops...)
+	return b
+}
+
+// AddData appends the shortest push of data.
+func (b *ScriptBuilder) AddData(data []byte) *ScriptBuilder {
+	switch {
+	case len(data) < OP_PUSHDATA1:
+		b.script = append(b.script, byte(len(data)))
+	case len(data) <= 0xff:
+		b.script = append(b.script, OP_PUSHDATA1, byte(len(data)))
+	default:
+		b.script = append(b.script, OP_PUSHDATA2, byte(len(data)), byte(len(data)>>8))
+	}
+	b.script = append(b.script, data...)
+	return b
+}
+
+// AddInt appends the shortest push of a number, using OP_0, OP_1NEGATE and OP_1-OP_16 where possible.
+func (b *ScriptBuilder) AddInt(n int64) *ScriptBuilder {
+	switch {
+	case n == 0:
+		return b.AddOp(OP_0)
+	case n == -1:
+		return b.AddOp(OP_1NEGATE)
+	case 1 <= n && n <= 16:
+		return b.AddOp(byte(OP_1 - 1 + n))
+	}
+	return b.AddData(ScriptNum(n))
+}
+
+// Script returns the built script.
+func (b *ScriptBuilder) Script() []byte {
+	return append([]byte(nil), b.script...)
+}
+
+// ScriptNum returns the minimal little-endian sign-magnitude encoding of n used in scripts.
+func ScriptNum(n int64) []byte {
+	if n == 0 {
+		return nil
+	}
+	negative := n < 0
+	abs := uint64(n)
+	if negative {
+		abs = uint64(-n)
+	}
+	var result []byte
+	for abs > 0 {
+		result = append(result, byte(abs))
+		abs >>= 8
+	}
+	if result[len(result)-1]&0x80 != 0 {
+		if negative {
+			result = append(result, 0x80)
+		} else {
+			result = append(result, 0x00)
+		}
+	} else if negative {
+		result[len(result)-1] |= 0x80
+	}
+	return result
+}
+
+// Hash160 returns RIPEMD160(SHA256(data)).
+func Hash160(data []byte) []byte {
+	hash := ripemd160.New()
+	intermediate := sha256.Sum256(data)
+	hash.Write(intermediate[:])
+	return hash.Sum(nil)
+}
+
+// taggedHash computes the tagged hash defined in BIP 340.
+func taggedHash(tag string, data ...[]byte) [32]byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	hash := sha256.New()
+	hash.Write(tagHash[:])
+	hash.Write(tagHash[:])
+	for _, d := range data {
+		hash.Write(d)
+	}
+	return [32]byte(hash.Sum(nil))
+}
+
+func p2pkScript(publicKey []byte) []byte {
+	return new(ScriptBuilder).AddData(publicKey).AddOp(OP_CHECKSIG).Script()
+}
+
+func p2pkhScript(publicKey []byte) []byte {
+	return new(ScriptBuilder).AddOp(OP_DUP, OP_HASH160).AddData(Hash160(publicKey)).AddOp(OP_EQUALVERIFY, OP_CHECKSIG).Script()
+}
+
+func p2wpkhScript(publicKey []byte) []byte {
+	return new(ScriptBuilder).AddOp(OP_0).AddData(Hash160(publicKey)).Script()
+}
+
+func p2shScript(redeemScript []byte) []byte {
+	return new(ScriptBuilder).AddOp(OP_HASH160).AddData(Hash160(redeemScript)).AddOp(OP_EQUAL).Script()
+}
+
+func p2wshScript(witnessScript []byte) []byte {
+	hash := sha256.Sum256(witnessScript)
+	return new(ScriptBuilder).AddOp(OP_0).AddData(hash[:]).Script()
+}
+
+func p2trScript(outputKey [32]byte) []byte {
+	return new(ScriptBuilder).AddOp(OP_1).AddData(outputKey[:]).Script()
+}
+
+func multiScript(threshold int, publicKeys [][]byte) []byte {
+	builder := new(ScriptBuilder).AddInt(int64(threshold))
+	for _, publicKey := range publicKeys {
+		builder.AddData(publicKey)
+	}
+	return builder.AddInt(int64(len(publicKeys))).AddOp(OP_CHECKMULTISIG).Script()
+}
@@ -0,0 +1,45 @@
+//go:build dudect
+
+package bip32
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/koba-e964/bip32-typesafe/internal/dudect"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+)
+
+func randomMasterKey() *PrivateKey {
+	seed := make([]byte, 32)
+	if _, err := rand.Read(seed); err != nil {
+		panic(err)
+	}
+	return NewMasterKey(seed)
+}
+
+// fixedMasterKey returns a master key with many zero bits.
+func fixedMasterKey() *PrivateKey {
+	fixed := NewMasterKey(make([]byte, 32))
+	fixed.privateKey = secp256k1.NewSecretScalar(secp256k1.Scalar{31: 1})
+	return fixed
+}
+
+func TestDudectNewChildKey(t *testing.T) {
+	// memoises the public keys, whose computation is tested with GEProjPoint
+	prepare := func(k *PrivateKey) *PrivateKey {
+		k.publicPart()
+		return k
+	}
+	result := dudect.Measure(*dudect.Measurements/10, 1, func() *PrivateKey { return prepare(fixedMasterKey()) }, func() *PrivateKey { return prepare(randomMasterKey()) }, func(k *PrivateKey) {
+		_, _ = k.NewChildKey(FirstHardenedChildIndex)
+	})
+	dudect.Report(t, result)
+}
+
+func TestDudectDeserializePrivateKey(t *testing.T) {
+	result := dudect.Measure(*dudect.Measurements, 10, fixedMasterKey().Serialize, func() [KeyLengthInBytes]byte { return randomMasterKey().Serialize() }, func(data [KeyLengthInBytes]byte) {
+		_, _ = DeserializePrivateKey(data)
+	})
+	dudect.Report(t, result)
+}
@@ -0,0 +1,250 @@
+package bip32
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted private keys are stored in the following format, in which integers are big-endian:
+//
+//	magic "B32E" (4) || format version (1) || KDF (1) || KDF parameters (9) || salt (16) || nonce (24) ||
+//	key version (4) || depth (1) || parent fingerprint (4) || child number (4) ||
+//	origin flag (1) [|| master fingerprint (4) || path (4 * depth)] ||
+//	XChaCha20-Poly1305 ciphertext of (chain code (32) || private key (32)) with tag (16)
+//
+// Everything before the ciphertext is the associated data, so the metadata is authenticated
+// but can be read without the passphrase.
+
+// KDF is a key derivation function which derives encryption keys from passphrases.
+type KDF byte
+
+const (
+	KDFArgon2id KDF = 1
+	KDFScrypt   KDF = 2
+)
+
+// EncryptionParams are the parameters of the KDF used by EncryptPrivateKey.
+type EncryptionParams struct {
+	KDF KDF
+	// parameters of Argon2id
+	Time    uint32 // number of passes
+	Memory  uint32 // in KiB
+	Threads uint8
+	// parameters of scrypt
+	LogN uint8 // N = 2^LogN
+	R    uint32
+	P    uint32
+}
+
+var (
+	// DefaultArgon2idParams are the second recommended parameters of RFC 9106, which use 64 MiB of memory.
+	DefaultArgon2idParams = EncryptionParams{KDF: KDFArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+	// DefaultScryptParams are N = 2^17, r = 8 and p = 1, which use 128 MiB of memory.
+	DefaultScryptParams = EncryptionParams{KDF: KDFScrypt, LogN: 17, R: 8, P: 1}
+)
+
+const (
+	encryptedKeyVersion      = 1
+	encryptedKeySaltLength   = 16
+	encryptedKeyHeaderLength = 4 + 1 + 1 + 9 + encryptedKeySaltLength + chacha20poly1305.NonceSizeX
+	encryptedKeyPEMType      = "BIP32 ENCRYPTED PRIVATE KEY"
+	// KDFs may use at most 4 GiB of memory and 64 passes, so that a crafted blob cannot exhaust the resources of the decrypting party
+	maxKDFMemoryKiB = 1 << 22
+	maxKDFPasses    = 64
+)
+
+var encryptedKeyMagic = []byte("B32E")
+
+func (params *EncryptionParams) validate() error {
+	switch params.KDF {
+	case KDFArgon2id:
+		if params.Time == 0 || params.Time > maxKDFPasses || params.Threads == 0 || params.Memory < 8*uint32(params.Threads) || params.Memory > maxKDFMemoryKiB {
+			return ErrorInvalidEncryptionParams
+		}
+	case KDFScrypt:
+		if params.LogN == 0 || params.LogN >= 32 || params.R == 0 || params.P == 0 || params.P > maxKDFPasses ||
+			uint64(params.R)*uint64(params.P) >= 1<<30 ||
+			// scrypt uses 128 * r * N bytes
+			uint64(params.R) > (maxKDFMemoryKiB<<10)>>(params.LogN+7) {
+			return ErrorInvalidEncryptionParams
+		}
+	default:
+		return ErrorInvalidEncryptionParams
+	}
+	return nil
+}
+
+func (params *EncryptionParams) marshal() [9]byte {
+	var result [9]byte
+	switch params.KDF {
+	case KDFArgon2id:
+		binary.BigEndian.PutUint32(result[:4], params.Time)
+		binary.BigEndian.PutUint32(result[4:8], params.Memory)
+		result[8] = params.Threads
+	case KDFScrypt:
+		result[0] = params.LogN
+		binary.BigEndian.PutUint32(result[1:5], params.R)
+		binary.BigEndian.PutUint32(result[5:], params.P)
+	}
+	return result
+}
+
+func unmarshalEncryptionParams(kdf KDF, data []byte) EncryptionParams {
+	params := EncryptionParams{KDF: kdf}
+	switch kdf {
+	case KDFArgon2id:
+		params.Time = binary.BigEndian.Uint32(data[:4])
+		params.Memory = binary.BigEndian.Uint32(data[4:8])
+		params.Threads = data[8]
+	case KDFScrypt:
+		params.LogN = data[0]
+		params.R = binary.BigEndian.Uint32(data[1:5])
+		params.P = binary.BigEndian.Uint32(data[5:])
+	}
+	return params
+}
+
+func (params *EncryptionParams) deriveKey(passphrase []byte, salt []byte) []byte {
+	if params.KDF == KDFArgon2id {
+		return argon2.IDKey(passphrase, salt, params.Time, params.Memory, params.Threads, chacha20poly1305.KeySize)
+	}
+	key, err := scrypt.Key(passphrase, salt, 1<<params.LogN, int(params.R), int(params.P), chacha20poly1305.KeySize)
+	if err != nil {
+		// unreachable: params are validated
+		panic(err)
+	}
+	return key
+}
+
+// EncryptPrivateKey encrypts k with passphrase, using params for the key derivation, and returns a binary blob.
+// The metadata of k (version, depth, parent fingerprint, child number and key origin) is not encrypted but authenticated.
+// ErrorInvalidEncryptionParams is returned if params are invalid, use more than 4 GiB of memory or more than 64 passes (Time or P).
+func EncryptPrivateKey(k *PrivateKey, passphrase string, params EncryptionParams) ([]byte, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	result := append([]byte{}, encryptedKeyMagic...)
+	result = append(result, encryptedKeyVersion, byte(params.KDF))
+	marshaled := params.marshal()
+	result = append(result, marshaled[:]...)
+	random := make([]byte, encryptedKeySaltLength+chacha20poly1305.NonceSizeX)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	result = append(result, random...)
+	salt, nonce := random[:encryptedKeySaltLength], random[encryptedKeySaltLength:]
+
+	result = append(result, k.version[:]...)
+	result = append(result, k.depth)
+	result = append(result, k.parentFingerprint[:]...)
+	result = append(result, k.childNumber[:]...)
+	if k.origin == nil {
+		result = append(result, 0)
+	} else {
+		result = append(result, 1)
+		result = append(result, k.origin.MasterFingerprint[:]...)
+		for _, childIdx := range k.origin.Path {
+			result = binary.BigEndian.AppendUint32(result, childIdx)
+		}
+	}
+
+	aead, err := chacha20poly1305.NewX(params.deriveKey([]byte(passphrase), salt))
+	if err != nil {
+		return nil, err
+	}
+	privateKey := k.privateKey.Declassify()
+	defer clear(privateKey[:])
+	plaintext := append(append(make([]byte, 0, 64), k.chainCode[:]...), privateKey[:]...)
+	defer clear(plaintext)
+	return aead.Seal(result, nonce, plaintext, result), nil
+}
+
+// DecryptPrivateKey decrypts a blob made by EncryptPrivateKey. The blob is authenticated before the key in it is parsed.
+// The following errors may be returned:
+//   - ErrorInvalidEncryptedKey: if blob is truncated or its header is malformed
+//   - ErrorUnsupportedEncryptedKeyVersion: if the format version of blob is unknown
+//   - ErrorInvalidEncryptionParams: if the KDF parameters in blob are invalid or too expensive
+//   - ErrorDecryptionFailed: if passphrase is wrong or blob is corrupted
+func DecryptPrivateKey(blob []byte, passphrase string) (*PrivateKey, error) {
+	if len(blob) < encryptedKeyHeaderLength || !bytes.Equal(blob[:4], encryptedKeyMagic) {
+		return nil, ErrorInvalidEncryptedKey
+	}
+	if blob[4] != encryptedKeyVersion {
+		return nil, ErrorUnsupportedEncryptedKeyVersion
+	}
+	params := unmarshalEncryptionParams(KDF(blob[5]), blob[6:15])
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	salt := blob[15 : 15+encryptedKeySaltLength]
+	nonce := blob[15+encryptedKeySaltLength : encryptedKeyHeaderLength]
+
+	// the length of the associated data depends on the depth and the origin flag
+	metadata := blob[encryptedKeyHeaderLength:]
+	if len(metadata) < 14 {
+		return nil, ErrorInvalidEncryptedKey
+	}
+	adLength := encryptedKeyHeaderLength + 14
+	switch metadata[13] {
+	case 0:
+	case 1:
+		adLength += 4 + 4*int(metadata[4])
+	default:
+		return nil, ErrorInvalidEncryptedKey
+	}
+	if len(blob) != adLength+64+chacha20poly1305.Overhead {
+		return nil, ErrorInvalidEncryptedKey
+	}
+
+	aead, err := chacha20poly1305.NewX(params.deriveKey([]byte(passphrase), salt))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, blob[adLength:], blob[:adLength])
+	if err != nil {
+		return nil, ErrorDecryptionFailed
+	}
+	defer clear(plaintext)
+
+	var data [KeyLengthInBytes]byte
+	defer clear(data[:])
+	copy(data[:13], metadata[:13])
+	copy(data[13:45], plaintext[:32])
+	copy(data[46:78], plaintext[32:])
+	chksum := checksum(data[:78])
+	copy(data[78:], chksum[:])
+	k, err := DeserializePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	if metadata[13] == 0 {
+		return k, nil
+	}
+	origin := KeyOrigin{MasterFingerprint: [4]byte(metadata[14:18]), Path: make([]uint32, metadata[4])}
+	for i := range origin.Path {
+		origin.Path[i] = binary.BigEndian.Uint32(metadata[18+4*i:])
+	}
+	return k.WithKeyOrigin(origin)
+}
+
+// ArmorEncryptedPrivateKey encodes a blob made by EncryptPrivateKey in PEM, so that it can be stored in text files.
+func ArmorEncryptedPrivateKey(blob []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: encryptedKeyPEMType, Bytes: blob}))
+}
+
+// DearmorEncryptedPrivateKey decodes the output of ArmorEncryptedPrivateKey.
+// ErrorInvalidEncryptedKey is returned if armored is not a single PEM block of an encrypted key.
+func DearmorEncryptedPrivateKey(armored string) ([]byte, error) {
+	block, rest := pem.Decode([]byte(armored))
+	if block == nil || block.Type != encryptedKeyPEMType || len(block.Headers) != 0 || strings.TrimSpace(string(rest)) != "" {
+		return nil, ErrorInvalidEncryptedKey
+	}
+	return block.Bytes, nil
+}
@@ -0,0 +1,67 @@
+package bip32
+
+import (
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Ethereum addresses with the mixed-case checksum of EIP-55.
+//
+// Spec: https://eips.ethereum.org/EIPS/eip-55
+//
+// Ethereum keys are derived at m/44'/60'/0'/0/i by MetaMask and most wallets, at m/44'/60'/i'/0/0 by Ledger Live,
+// and at m/44'/60'/0'/i by the legacy layout of Ledger.
+
+// EthereumAddress returns the Ethereum address of this PublicKey with the EIP-55 checksum, such as "0x9858EfFD232B4033E47d90003D41EC34EcaEda94".
+// The address is the last 20 bytes of Keccak-256 (not SHA3-256) of the 64-byte x || y of the uncompressed public key.
+// ErrorInvalidPublicKey is returned if the public key is not on the curve, as in UncompressedBytes.
+func (p *PublicKey) EthereumAddress() (string, error) {
+	uncompressed, err := p.UncompressedBytes()
+	if err != nil {
+		return "", err
+	}
+	hash := keccak256(uncompressed[1:])
+	return FormatEthereumAddress([20]byte(hash[12:])), nil
+}
+
+// FormatEthereumAddress returns "0x" followed by the hexadecimal representation of address, where letters are capitalized following EIP-55.
+func FormatEthereumAddress(address [20]byte) string {
+	lower := hex.EncodeToString(address[:])
+	// the i-th letter is capitalized if the i-th nibble of the hash of the lowercase hexadecimal is >= 8
+	hash := keccak256([]byte(lower))
+	result := []byte("0x" + lower)
+	for i := 0; i < len(lower); i++ {
+		if hash[i/2]>>(4*(1-i%2))&0x8 != 0 && 'a' <= lower[i] && lower[i] <= 'f' {
+			result[2+i] -= 'a' - 'A'
+		}
+	}
+	return string(result)
+}
+
+// ParseEthereumAddress decodes an Ethereum address and verifies its EIP-55 checksum. The following errors may be returned:
+//   - ErrorInvalidEthereumAddress: if address is not "0x" followed by 40 hexadecimal digits
+//   - ErrorChecksumMismatch: if the capitalization of address is not the one of FormatEthereumAddress
+//
+// Addresses in all lowercase or all uppercase, which EIP-55 regards as having no checksum, are rejected
+// unless their capitalization happens to be correct, since accepting them loses the protection from typos.
+func ParseEthereumAddress(address string) ([20]byte, error) {
+	var result [20]byte
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return [20]byte{}, ErrorInvalidEthereumAddress
+	}
+	if _, err := hex.Decode(result[:], []byte(address[2:])); err != nil {
+		return [20]byte{}, ErrorInvalidEthereumAddress
+	}
+	if FormatEthereumAddress(result) != address {
+		return [20]byte{}, ErrorChecksumMismatch
+	}
+	return result, nil
+}
+
+func keccak256(data []byte) [32]byte {
+	hash := sha3.NewLegacyKeccak256()
+	_, _ = hash.Write(data)
+	return [32]byte(hash.Sum(nil))
+}
@@ -0,0 +1,69 @@
+package bip32
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// PrivateKey and WIFKey redact their secrets when they are formatted by fmt or logged by log/slog.
+// Only the fingerprint, which is public, is shown. Secrets are exported only by explicit methods,
+// such as B58Serialize, Serialize and PrivateKey of PrivateKey, and WIF of WIFKey.
+// These methods have value receivers so that values, not only pointers, are redacted.
+
+// String returns a redacted representation of this PrivateKey, such as "bip32.PrivateKey(fingerprint=3442193e, depth=0, REDACTED)".
+func (p PrivateKey) String() string {
+	fp := p.Fingerprint()
+	return fmt.Sprintf("bip32.PrivateKey(fingerprint=%s, depth=%d, REDACTED)", hex.EncodeToString(fp[:]), p.depth)
+}
+
+// GoString returns a redacted representation of this PrivateKey, which is used by %#v.
+func (p PrivateKey) GoString() string {
+	return p.String()
+}
+
+// Format implements fmt.Formatter. It writes a redacted representation for any verb.
+func (p PrivateKey) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, p.String())
+}
+
+// LogValue implements slog.LogValuer. It returns a group of the fingerprint, the depth and the key origin if known.
+func (p PrivateKey) LogValue() slog.Value {
+	fp := p.Fingerprint()
+	attrs := []slog.Attr{
+		slog.String("fingerprint", hex.EncodeToString(fp[:])),
+		slog.Int("depth", int(p.depth)),
+	}
+	if origin := p.KeyOrigin(); origin != nil {
+		attrs = append(attrs, slog.String("origin", origin.String()))
+	}
+	attrs = append(attrs, slog.String("privateKey", "REDACTED"))
+	return slog.GroupValue(attrs...)
+}
+
+// String returns a redacted representation of this WIFKey, such as "bip32.WIFKey(fingerprint=3442193e, REDACTED)".
+func (w WIFKey) String() string {
+	fp := fingerprint(w.PublicKey())
+	return fmt.Sprintf("bip32.WIFKey(fingerprint=%s, REDACTED)", hex.EncodeToString(fp[:]))
+}
+
+// GoString returns a redacted representation of this WIFKey, which is used by %#v.
+func (w WIFKey) GoString() string {
+	return w.String()
+}
+
+// Format implements fmt.Formatter. It writes a redacted representation for any verb.
+func (w WIFKey) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, w.String())
+}
+
+// LogValue implements slog.LogValuer. It returns a group of the fingerprint of the public key and the network.
+func (w WIFKey) LogValue() slog.Value {
+	fp := fingerprint(w.PublicKey())
+	return slog.GroupValue(
+		slog.String("fingerprint", hex.EncodeToString(fp[:])),
+		slog.Bool("testnet", w.testnet),
+		slog.String("privateKey", "REDACTED"),
+	)
+}
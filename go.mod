@@ -5,14 +5,19 @@ go 1.24.0
 toolchain go1.24.1
 
 require (
+	filippo.io/edwards25519 v1.1.0
 	github.com/FactomProject/btcutilecc v0.0.0-20130527213604-d3a63a5752ec
 	github.com/koba-e964/base58-go v0.1.2
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/crypto v0.45.0
+	golang.org/x/tools v0.39.0
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/mod v0.30.0 // indirect
+	golang.org/x/sync v0.18.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
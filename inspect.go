@@ -0,0 +1,243 @@
+package bip32
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/koba-e964/base58-go"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+)
+
+// DiagnosticCode identifies a check that an extended key failed.
+type DiagnosticCode int
+
+const (
+	DiagnosticInvalidBase58Character DiagnosticCode = iota + 1
+	DiagnosticInvalidLength
+	DiagnosticChecksumMismatch
+	DiagnosticUnknownVersion
+	DiagnosticZeroDepthAndNonZeroParentFingerprint
+	DiagnosticZeroDepthAndNonZeroIndex
+	DiagnosticPrivateKeyDataInPublicKey // public version, but the key data starts with 0x00
+	DiagnosticUncompressedPublicKey     // the key data starts with 0x04
+	DiagnosticInvalidPublicKeyPrefix
+	DiagnosticPublicKeyNotOnCurve
+	DiagnosticPublicKeyDataInPrivateKey // private version, but the key data is a valid compressed point
+	DiagnosticInvalidPrivateKeyPrefix
+	DiagnosticPrivateKeyNotInRange
+)
+
+// Diagnostic describes a check that an extended key failed.
+type Diagnostic struct {
+	Code    DiagnosticCode
+	Message string
+	// Err is the Reason of the *DecodeError which B58DeserializePrivateKey or B58DeserializePublicKey returns because of this problem.
+	Err error
+	// Suggestion is a hint on how the key may have been broken, or "" if there is none.
+	Suggestion string
+}
+
+// Inspection is the result of Inspect. Fields which could not be decoded are left zero.
+type Inspection struct {
+	Encoded string
+	// VersionName is "xprv", "xpub", "tprv" or "tpub" for versions of BIP 32, the name of a SLIP-132 version such as "zpub", or "" if unknown.
+	VersionName       string
+	Version           [4]byte
+	IsPrivate         bool // true if the version is of private keys, or the version is unknown and the key data starts with 0x00
+	IsTestnet         bool
+	Depth             byte
+	ParentFingerprint [4]byte
+	ChildNumber       uint32
+	ChainCode         [32]byte
+	KeyPrefix         byte                  // the first byte of the key data; the rest is not kept, as it may be a private key
+	PublicKey         *secp256k1.Compressed // nil if the key data is invalid
+	Fingerprint       [4]byte               // zero if PublicKey is nil
+	Checksum          [4]byte
+	ExpectedChecksum  [4]byte
+	Diagnostics       []Diagnostic // empty if the key is valid
+}
+
+// Valid returns true if the inspected key passed all checks.
+func (r *Inspection) Valid() bool {
+	return len(r.Diagnostics) == 0
+}
+
+func (r *Inspection) add(code DiagnosticCode, err error, suggestion string, format string, args ...any) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{
+		Code:       code,
+		Message:    fmt.Sprintf(format, args...),
+		Err:        err,
+		Suggestion: suggestion,
+	})
+}
+
+type versionInfo struct {
+	name      string
+	isPrivate bool
+	isTestnet bool
+	slip132   string // the script type of SLIP-132 versions
+}
+
+// versionInfos are the versions of BIP 32 and SLIP-132. The latter are recognised only to give suggestions.
+var versionInfos = map[[4]byte]versionInfo{
+	{0x04, 0x88, 0xb2, 0x1e}: {name: "xpub"},
+	{0x04, 0x88, 0xad, 0xe4}: {name: "xprv", isPrivate: true},
+	{0x04, 0x35, 0x87, 0xcf}: {name: "tpub", isTestnet: true},
+	{0x04, 0x35, 0x83, 0x94}: {name: "tprv", isPrivate: true, isTestnet: true},
+	{0x04, 0x9d, 0x7c, 0xb2}: {name: "ypub", slip132: "P2SH-P2WPKH"},
+	{0x04, 0x9d, 0x78, 0x78}: {name: "yprv", isPrivate: true, slip132: "P2SH-P2WPKH"},
+	{0x04, 0xb2, 0x47, 0x46}: {name: "zpub", slip132: "P2WPKH"},
+	{0x04, 0xb2, 0x43, 0x0c}: {name: "zprv", isPrivate: true, slip132: "P2WPKH"},
+	{0x04, 0x4a, 0x52, 0x62}: {name: "upub", isTestnet: true, slip132: "P2SH-P2WPKH"},
+	{0x04, 0x4a, 0x4e, 0x28}: {name: "uprv", isPrivate: true, isTestnet: true, slip132: "P2SH-P2WPKH"},
+	{0x04, 0x5f, 0x1c, 0xf6}: {name: "vpub", isTestnet: true, slip132: "P2WPKH"},
+	{0x04, 0x5f, 0x18, 0xbc}: {name: "vprv", isPrivate: true, isTestnet: true, slip132: "P2WPKH"},
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Inspect decodes a base58-encoded extended key as far as possible and reports every check it fails,
+// in the order B58DeserializePrivateKey and B58DeserializePublicKey perform them.
+// Unlike these functions, Inspect goes on after a failed check, so it can report all problems of a key at once.
+// It is meant for explaining why a key is rejected; keys should be parsed with the deserialization functions.
+//
+// If the key has an invalid character or a wrong length, no fields can be decoded and only these problems are reported.
+// The private key in the key data is never kept in the result.
+func Inspect(encoded string) *Inspection {
+	r := &Inspection{Encoded: encoded}
+
+	if len(encoded) != 111 {
+		suggestion := ""
+		if len(encoded) < 111 && hasKnownPrefix(encoded) {
+			suggestion = fmt.Sprintf("the key looks truncated; %d characters are missing", 111-len(encoded))
+		} else if len(encoded) > 111 && hasKnownPrefix(encoded) {
+			suggestion = fmt.Sprintf("the key has %d extra characters; check whether other text was copied with it", len(encoded)-111)
+		}
+		r.add(DiagnosticInvalidLength, ErrorInvalidKeyLength, suggestion, "length is %d characters, not 111", len(encoded))
+	}
+
+	for i, char := range encoded {
+		if strings.ContainsRune(base58Alphabet, char) {
+			continue
+		}
+		suggestion := ""
+		switch {
+		case strings.ContainsRune(" \t\r\n", char):
+			suggestion = "remove the whitespace"
+		case strings.ContainsRune("0OIl", char):
+			suggestion = "0, O, I and l are not used in base58; the key may have been mistyped"
+		}
+		r.add(DiagnosticInvalidBase58Character, ErrorInvalidEncoding, suggestion, "invalid base58 character %q at offset %d", char, i)
+	}
+	if len(r.Diagnostics) != 0 {
+		return r
+	}
+
+	var data [KeyLengthInBytes]byte
+	defer clear(data[:])
+	// 111 base58 characters always fit in 82 bytes
+	base58.Decode(encoded, data[:])
+
+	r.Version = [4]byte(data[:4])
+	r.Depth = data[4]
+	r.ParentFingerprint = [4]byte(data[5:9])
+	r.ChildNumber = binary.BigEndian.Uint32(data[9:13])
+	r.ChainCode = [32]byte(data[13:45])
+	r.KeyPrefix = data[45]
+	r.Checksum = [4]byte(data[78:])
+	r.ExpectedChecksum = checksum(data[:78])
+
+	if r.Checksum != r.ExpectedChecksum {
+		r.add(DiagnosticChecksumMismatch, ErrorChecksumMismatch, "a character may have been mistyped or changed", "checksum is %x, but %x is expected", r.Checksum, r.ExpectedChecksum)
+	}
+
+	info, known := versionInfos[r.Version]
+	r.VersionName = info.name
+	r.IsPrivate = info.isPrivate || (!known && r.KeyPrefix == 0)
+	r.IsTestnet = info.isTestnet
+	if !known || info.slip132 != "" {
+		suggestion := ""
+		if known {
+			standard := "xpub"
+			if info.isPrivate && info.isTestnet {
+				standard = "tprv"
+			} else if info.isPrivate {
+				standard = "xprv"
+			} else if info.isTestnet {
+				standard = "tpub"
+			}
+			suggestion = fmt.Sprintf("this is a SLIP-132 %s key for %s; convert it to %s and describe the script type with an output descriptor", info.name, info.slip132, standard)
+		}
+		r.add(DiagnosticUnknownVersion, ErrorInvalidVersion, suggestion, "version %x is not of BIP 32 extended keys", r.Version)
+	}
+
+	if r.Depth == 0 && r.ParentFingerprint != [4]byte{} {
+		r.add(DiagnosticZeroDepthAndNonZeroParentFingerprint, ErrorZeroDepthAndNonZeroParentFingerprint, "", "depth is 0, but parent fingerprint is %x", r.ParentFingerprint)
+	}
+	if r.Depth == 0 && r.ChildNumber != 0 {
+		r.add(DiagnosticZeroDepthAndNonZeroIndex, ErrorZeroDepthAndNonZeroIndex, "", "depth is 0, but child number is %d", r.ChildNumber)
+	}
+
+	if r.IsPrivate {
+		r.inspectPrivateKeyData(data)
+	} else {
+		r.inspectPublicKeyData(data)
+	}
+	if r.PublicKey != nil {
+		r.Fingerprint = fingerprint(*r.PublicKey)
+	}
+	return r
+}
+
+func hasKnownPrefix(encoded string) bool {
+	for _, prefix := range []string{"xpub", "xprv", "tpub", "tprv"} {
+		if strings.HasPrefix(encoded, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Inspection) inspectPublicKeyData(data [KeyLengthInBytes]byte) {
+	switch r.KeyPrefix {
+	case 0x00:
+		r.add(DiagnosticPrivateKeyDataInPublicKey, ErrorInvalidPublicKey, "the key data is a private key; the version may have been changed to a public one without computing the public key", "version is of public keys, but the key data starts with 0x00")
+	case 0x02, 0x03:
+		publicKey := secp256k1.Compressed(data[45:78])
+		if _, err := publicKey.Uncompress(); err != nil {
+			r.add(DiagnosticPublicKeyNotOnCurve, ErrorInvalidPublicKey, "", "public key %x is not a point on secp256k1", publicKey)
+			return
+		}
+		r.PublicKey = &publicKey
+	case 0x04:
+		r.add(DiagnosticUncompressedPublicKey, ErrorInvalidPublicKey, "", "public key has the prefix 0x04 of uncompressed points; extended keys must have compressed points")
+	default:
+		r.add(DiagnosticInvalidPublicKeyPrefix, ErrorInvalidPublicKey, "", "public key has an invalid prefix 0x%02x", r.KeyPrefix)
+	}
+}
+
+func (r *Inspection) inspectPrivateKeyData(data [KeyLengthInBytes]byte) {
+	if r.KeyPrefix != 0x00 {
+		if r.KeyPrefix == 0x02 || r.KeyPrefix == 0x03 {
+			publicKey := secp256k1.Compressed(data[45:78])
+			if _, err := publicKey.Uncompress(); err == nil {
+				r.PublicKey = &publicKey
+				r.add(DiagnosticPublicKeyDataInPrivateKey, ErrorInvalidPrivateKey, "the key data is a public key; the version may have been changed to a private one, which cannot recover the private key", "version is of private keys, but the key data is a public key")
+				return
+			}
+		}
+		r.add(DiagnosticInvalidPrivateKeyPrefix, ErrorInvalidPrivateKey, "", "private key has an invalid prefix 0x%02x, not 0x00", r.KeyPrefix)
+		return
+	}
+	privateKey := secp256k1.NewSecretScalar(secp256k1.Scalar(data[46:78]))
+	defer privateKey.Clear()
+	if isPrivateKeyInRange(privateKey) != 1 {
+		r.add(DiagnosticPrivateKeyNotInRange, ErrorPrivateKeyNotInRange, "", "private key is not in range [1, n-1]")
+		return
+	}
+	var publicKey secp256k1.Point
+	publicKey.GESecretPoint(privateKey)
+	compressed := publicKey.Compress()
+	r.PublicKey = &compressed
+}
@@ -0,0 +1,118 @@
+// Package dudect implements a statistical test of timing leakage, following dudect.
+//
+// Spec: Oscar Reparaz, Josep Balasch and Ingrid Verbauwhede, "Dude, is my code constant time?", DATE 2017. https://eprint.iacr.org/2016/1123
+//
+// A function is run many times with inputs of two classes, a fixed input and random inputs, in a random order.
+// If the distributions of the execution times of the two classes differ, the function leaks information about its input through timing.
+// The difference is measured with Welch's t-test; |t| > LeakageThreshold is considered as evidence of leakage.
+// Like dudect, the test is also performed on the measurements cropped at several percentiles, which removes the long tail caused by interrupts and GC,
+// and the largest |t| is reported.
+//
+// A small |t| is not a proof of constant-time behaviour: it only means that no leakage was detected with the given number of measurements.
+package dudect
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// LeakageThreshold is the threshold of |t| above which a function is considered to leak timing information, as in dudect.
+const LeakageThreshold = 4.5
+
+// number of cropped tests, as in dudect
+const numberOfCrops = 100
+
+// Result is the result of Measure.
+type Result struct {
+	Measurements int
+	// T is Welch's t-statistic with the largest absolute value among the tests on all measurements and on the cropped ones.
+	T float64
+	// Crop is the percentile at which the measurements were cropped to obtain T, or 1 if T was obtained from all measurements.
+	Crop float64
+}
+
+// Leaky returns true if |T| exceeds LeakageThreshold.
+func (r Result) Leaky() bool {
+	return math.Abs(r.T) > LeakageThreshold
+}
+
+func (r Result) String() string {
+	return fmt.Sprintf("measurements = %d, max |t| = %.2f (crop = %.4f), leaky = %v", r.Measurements, math.Abs(r.T), r.Crop, r.Leaky())
+}
+
+// Measure runs f measurements times, each time with a value returned by fixed or random chosen at random, and performs Welch's t-test on the execution times.
+// fixed must return the same value every time; it is a function so that inputs of both classes can be allocated separately,
+// as sharing memory among the inputs of one class would make them faster because of caches.
+// Each measurement runs f repeat times with the same input, so that fast functions take much longer than the resolution of the clock.
+// All inputs are prepared before the measurement, so the costs of fixed and random are not measured.
+func Measure[T any](measurements int, repeat int, fixed func() T, random func() T, f func(T)) Result {
+	classes := make([]int, measurements)
+	inputs := make([]T, measurements)
+	for i := range inputs {
+		classes[i] = rand.IntN(2)
+		if classes[i] == 0 {
+			inputs[i] = fixed()
+		} else {
+			inputs[i] = random()
+		}
+	}
+
+	// warm-up, which is discarded
+	for i := 0; i < min(measurements, 1000); i++ {
+		f(inputs[i])
+	}
+
+	durations := make([]float64, measurements)
+	for i, input := range inputs {
+		start := time.Now()
+		for j := 0; j < repeat; j++ {
+			f(input)
+		}
+		durations[i] = float64(time.Since(start))
+	}
+	return analyze(classes, durations)
+}
+
+func analyze(classes []int, durations []float64) Result {
+	result := Result{Measurements: len(durations), T: welchT(classes, durations, math.Inf(1)), Crop: 1}
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	for i := 0; i < numberOfCrops; i++ {
+		crop := 1 - math.Pow(0.5, 10*float64(i+1)/numberOfCrops)
+		threshold := sorted[int(crop*float64(len(sorted)-1))]
+		if t := welchT(classes, durations, threshold); math.Abs(t) > math.Abs(result.T) {
+			result.T = t
+			result.Crop = crop
+		}
+	}
+	return result
+}
+
+// welchT returns Welch's t-statistic of the durations at most threshold, or 0 if a class has fewer than 2 of them.
+func welchT(classes []int, durations []float64, threshold float64) float64 {
+	// Welford's online algorithm
+	var n, mean, m2 [2]float64
+	for i, duration := range durations {
+		if duration > threshold {
+			continue
+		}
+		class := classes[i]
+		n[class]++
+		delta := duration - mean[class]
+		mean[class] += delta / n[class]
+		m2[class] += delta * (duration - mean[class])
+	}
+	if n[0] < 2 || n[1] < 2 {
+		return 0
+	}
+	variance0 := m2[0] / (n[0] - 1)
+	variance1 := m2[1] / (n[1] - 1)
+	denominator := math.Sqrt(variance0/n[0] + variance1/n[1])
+	if denominator == 0 {
+		return 0
+	}
+	return (mean[0] - mean[1]) / denominator
+}
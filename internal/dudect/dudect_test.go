@@ -0,0 +1,41 @@
+package dudect
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWelchT(t *testing.T) {
+	// class 0: 1, 2, 3 (mean 2, variance 1), class 1: 4, 6, 8 (mean 6, variance 4)
+	classes := []int{0, 1, 0, 1, 0, 1}
+	durations := []float64{1, 4, 2, 6, 3, 8}
+	assert.InDelta(t, -4/math.Sqrt(1.0/3+4.0/3), welchT(classes, durations, math.Inf(1)), 1e-12)
+	// too few measurements after cropping
+	assert.Equal(t, 0.0, welchT(classes, durations, 3))
+	// no variance
+	assert.Equal(t, 0.0, welchT(classes, []float64{1, 1, 1, 1, 1, 1}, math.Inf(1)))
+}
+
+func TestAnalyze(t *testing.T) {
+	classes := make([]int, 10000)
+	same := make([]float64, len(classes))
+	different := make([]float64, len(classes))
+	for i := range classes {
+		classes[i] = i % 2
+		// the same distribution for both classes
+		same[i] = float64(100 + (i/2)%7)
+		different[i] = same[i] + float64(classes[i])
+	}
+	assert.False(t, analyze(classes, same).Leaky())
+	result := analyze(classes, different)
+	assert.True(t, result.Leaky())
+	assert.Less(t, result.T, 0.0)
+	assert.Equal(t, len(classes), result.Measurements)
+}
+
+func TestMeasure(t *testing.T) {
+	result := Measure(100, 1, func() int { return 0 }, func() int { return 1 }, func(int) {})
+	assert.Equal(t, 100, result.Measurements)
+}
@@ -0,0 +1,24 @@
+//go:build dudect
+
+package dudect
+
+import (
+	"flag"
+	"testing"
+)
+
+// Timing leakage tests of this module are behind the dudect build tag, and take a few minutes. Run them with:
+//
+//	go test -tags dudect -run Dudect -v . ./secp256k1
+
+// Measurements is the number of measurements of each timing leakage test, given by the -dudect.measurements flag.
+var Measurements = flag.Int("dudect.measurements", 100000, "number of measurements of each timing leakage test")
+
+// Report logs result and marks t as failed if result is leaky.
+func Report(t testing.TB, result Result) {
+	t.Helper()
+	t.Log(result)
+	if result.Leaky() {
+		t.Errorf("timing leakage detected: t = %.2f", result.T)
+	}
+}
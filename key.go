@@ -1,6 +1,7 @@
 package bip32
 
 import (
+	"crypto/subtle"
 	"encoding/hex"
 
 	"github.com/koba-e964/base58-go"
@@ -23,3 +24,11 @@ var (
 func base58EncodeKeyBytes(a [82]byte) string {
 	return base58.Encode(a[:], 111)
 }
+
+// base58DecodeCanonical decodes encoded into data, and returns 1 if encoded is the encoding of data and 0 otherwise.
+// base58.Decode maps characters outside the alphabet to digits and ignores overflows, so the result is encoded back and compared.
+// It runs in constant-time.
+func base58DecodeCanonical(encoded string, data []byte) int {
+	base58.Decode(encoded, data)
+	return subtle.ConstantTimeCompare([]byte(base58.Encode(data, len(encoded))), []byte(encoded))
+}
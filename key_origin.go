@@ -0,0 +1,77 @@
+package bip32
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// KeyOrigin describes where a key comes from: the fingerprint of the master key and the derivation path from the master key.
+// It is used in PSBTs (BIP 174) and output script descriptors (BIP 380).
+type KeyOrigin struct {
+	MasterFingerprint [4]byte
+	Path              []uint32 // child indices from the master key; hardened indices are >= FirstHardenedChildIndex
+}
+
+// String returns the representation of this KeyOrigin used in descriptors, such as "d34db33f/84'/0'/0'".
+func (o *KeyOrigin) String() string {
+	var builder strings.Builder
+	builder.WriteString(hex.EncodeToString(o.MasterFingerprint[:]))
+	for _, childIdx := range o.Path {
+		builder.WriteByte('/')
+		builder.WriteString(formatChildIndex(childIdx))
+	}
+	return builder.String()
+}
+
+// formatChildIndex formats a child index as in paths, such as "1" or "84'".
+func formatChildIndex(childIdx uint32) string {
+	if childIdx >= FirstHardenedChildIndex {
+		return strconv.FormatUint(uint64(childIdx-FirstHardenedChildIndex), 10) + "'"
+	}
+	return strconv.FormatUint(uint64(childIdx), 10)
+}
+
+func (o *KeyOrigin) clone() *KeyOrigin {
+	if o == nil {
+		return nil
+	}
+	result := KeyOrigin{
+		MasterFingerprint: o.MasterFingerprint,
+	}
+	if len(o.Path) > 0 {
+		result.Path = append([]uint32(nil), o.Path...)
+	}
+	return &result
+}
+
+// child returns the KeyOrigin of the child key at childIdx, or nil if o is nil.
+func (o *KeyOrigin) child(childIdx uint32) *KeyOrigin {
+	if o == nil {
+		return nil
+	}
+	result := KeyOrigin{
+		MasterFingerprint: o.MasterFingerprint,
+		Path:              make([]uint32, len(o.Path)+1),
+	}
+	copy(result.Path, o.Path)
+	result.Path[len(o.Path)] = childIdx
+	return &result
+}
+
+// checkKeyOrigin checks if origin is consistent with the key with the given metadata.
+func checkKeyOrigin(origin KeyOrigin, depth byte, childNumber [4]byte, fingerprint [4]byte) error {
+	if len(origin.Path) != int(depth) {
+		return ErrorKeyOriginMismatch
+	}
+	if depth == 0 {
+		if origin.MasterFingerprint != fingerprint {
+			return ErrorKeyOriginMismatch
+		}
+		return nil
+	}
+	if uint32ToBytes(origin.Path[depth-1]) != childNumber {
+		return ErrorKeyOriginMismatch
+	}
+	return nil
+}
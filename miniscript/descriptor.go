@@ -0,0 +1,337 @@
+package miniscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"strings"
+
+	"github.com/koba-e964/bip32-typesafe/descriptor"
+)
+
+// maximum depth of a taproot tree (BIP 341)
+const maxTaprootTreeDepth = 128
+
+// leaf version of Tapscript (BIP 342)
+const tapscriptLeafVersion = 0xc0
+
+// Descriptor is a parsed wsh(MINISCRIPT) or tr(KEY) or tr(KEY,TREE) descriptor.
+type Descriptor struct {
+	node        *Node           // for wsh
+	internalKey *descriptor.Key // for tr
+	tree        *TapTree        // for tr, nil if there is no script path
+}
+
+// TapTree is a taproot script tree: either a leaf or a branch with two children.
+type TapTree struct {
+	Leaf        *Node // nil if this is a branch
+	Left, Right *TapTree
+}
+
+// ParseDescriptor parses a wsh(MINISCRIPT), tr(KEY) or tr(KEY,TREE) descriptor, where TREE is a miniscript expression
+// or {TREE,TREE}. If s has a checksum, it is verified. Every miniscript expression must be sane.
+func ParseDescriptor(s string) (*Descriptor, error) {
+	desc, err := descriptor.StripChecksum(s)
+	if err != nil {
+		return nil, err
+	}
+	d := Descriptor{}
+	switch {
+	case strings.HasPrefix(desc, "wsh(") && strings.HasSuffix(desc, ")"):
+		node, err := parseSane(desc[len("wsh("):len(desc)-1], ContextP2WSH)
+		if err != nil {
+			return nil, err
+		}
+		d.node = node
+	case strings.HasPrefix(desc, "tr(") && strings.HasSuffix(desc, ")"):
+		args, err := descriptor.SplitArgs(desc[len("tr(") : len(desc)-1])
+		if err != nil {
+			return nil, err
+		}
+		if len(args) > 2 {
+			return nil, descriptor.ErrorInvalidDescriptor
+		}
+		internalKey, err := descriptor.ParseKey(args[0])
+		if err != nil {
+			return nil, err
+		}
+		d.internalKey = internalKey
+		if len(args) == 2 {
+			tree, err := parseTapTree(args[1], 0)
+			if err != nil {
+				return nil, err
+			}
+			d.tree = tree
+		}
+	default:
+		return nil, descriptor.ErrorInvalidDescriptor
+	}
+	if _, err := d.multipathLen(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func parseSane(s string, ctx Context) (*Node, error) {
+	node, err := Parse(s, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !node.IsSane() {
+		return nil, ErrorNotSane
+	}
+	return node, nil
+}
+
+func parseTapTree(s string, depth int) (*TapTree, error) {
+	if !strings.HasPrefix(s, "{") {
+		leaf, err := parseSane(s, ContextTapscript)
+		if err != nil {
+			return nil, err
+		}
+		return &TapTree{Leaf: leaf}, nil
+	}
+	if depth >= maxTaprootTreeDepth {
+		return nil, ErrorTreeTooDeep
+	}
+	if !strings.HasSuffix(s, "}") {
+		return nil, descriptor.ErrorInvalidDescriptor
+	}
+	args, err := descriptor.SplitArgs(s[1 : len(s)-1])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) != 2 {
+		return nil, descriptor.ErrorInvalidDescriptor
+	}
+	left, err := parseTapTree(args[0], depth+1)
+	if err != nil {
+		return nil, err
+	}
+	right, err := parseTapTree(args[1], depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return &TapTree{Left: left, Right: right}, nil
+}
+
+// Node returns the miniscript expression of a wsh() descriptor, or nil for a tr() descriptor.
+func (d *Descriptor) Node() *Node {
+	return d.node
+}
+
+// InternalKey returns the internal key of a tr() descriptor, or nil for a wsh() descriptor.
+func (d *Descriptor) InternalKey() *descriptor.Key {
+	return d.internalKey
+}
+
+// TapTree returns the script tree of a tr() descriptor, or nil if there is none.
+func (d *Descriptor) TapTree() *TapTree {
+	return d.tree
+}
+
+// Keys returns all KEY expressions in this Descriptor in the order of appearance.
+func (d *Descriptor) Keys() []*descriptor.Key {
+	if d.node != nil {
+		return d.node.Keys()
+	}
+	return append([]*descriptor.Key{d.internalKey}, d.tree.keys()...)
+}
+
+func (t *TapTree) keys() []*descriptor.Key {
+	switch {
+	case t == nil:
+		return nil
+	case t.Leaf != nil:
+		return t.Leaf.Keys()
+	}
+	return append(t.Left.keys(), t.Right.keys()...)
+}
+
+// IsRange returns true if this Descriptor has a ranged key.
+func (d *Descriptor) IsRange() bool {
+	for _, key := range d.Keys() {
+		if key.IsRange() {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Descriptor) multipathLen() (int, error) {
+	result := 1
+	for _, key := range d.Keys() {
+		if n := key.MultipathLen(); n > 1 {
+			if result > 1 && result != n {
+				return 0, descriptor.ErrorMultipathLength
+			}
+			result = n
+		}
+	}
+	return result, nil
+}
+
+// Descriptors splits this Descriptor with multipath keys (BIP 389) into single-path descriptors.
+// If this Descriptor has no multipath keys, it returns a slice with only this Descriptor.
+func (d *Descriptor) Descriptors() []*Descriptor {
+	// multipathLen was checked in ParseDescriptor
+	n, _ := d.multipathLen()
+	result := make([]*Descriptor, n)
+	for i := 0; i < n; i++ {
+		result[i] = &Descriptor{
+			node:        d.node.singlePath(i),
+			internalKey: d.internalKey,
+			tree:        d.tree.singlePath(i),
+		}
+		if d.internalKey != nil {
+			result[i].internalKey = d.internalKey.SinglePath(i)
+		}
+	}
+	return result
+}
+
+func (n *Node) singlePath(i int) *Node {
+	if n == nil {
+		return nil
+	}
+	result := *n
+	result.keys = make([]*descriptor.Key, len(n.keys))
+	for j, key := range n.keys {
+		result.keys[j] = key.SinglePath(i)
+	}
+	result.subs = make([]*Node, len(n.subs))
+	for j, sub := range n.subs {
+		result.subs[j] = sub.singlePath(i)
+	}
+	return &result
+}
+
+func (t *TapTree) singlePath(i int) *TapTree {
+	switch {
+	case t == nil:
+		return nil
+	case t.Leaf != nil:
+		return &TapTree{Leaf: t.Leaf.singlePath(i)}
+	}
+	return &TapTree{Left: t.Left.singlePath(i), Right: t.Right.singlePath(i)}
+}
+
+// String returns the descriptor with its checksum.
+func (d *Descriptor) String() string {
+	var desc string
+	switch {
+	case d.node != nil:
+		desc = "wsh(" + d.node.String() + ")"
+	case d.tree != nil:
+		desc = "tr(" + d.internalKey.String() + "," + d.tree.String() + ")"
+	default:
+		desc = "tr(" + d.internalKey.String() + ")"
+	}
+	// desc consists of valid characters only
+	result, _ := descriptor.AddChecksum(desc)
+	return result
+}
+
+// String returns the TREE expression.
+func (t *TapTree) String() string {
+	if t.Leaf != nil {
+		return t.Leaf.String()
+	}
+	return "{" + t.Left.String() + "," + t.Right.String() + "}"
+}
+
+// MerkleRoot returns the root of the Merkle tree of tapleaf hashes (BIP 341) at index.
+func (t *TapTree) MerkleRoot(index uint32) ([32]byte, error) {
+	if t.Leaf != nil {
+		script, err := t.Leaf.Script(index)
+		if err != nil {
+			return [32]byte{}, err
+		}
+		return TapLeafHash(script), nil
+	}
+	left, err := t.Left.MerkleRoot(index)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	right, err := t.Right.MerkleRoot(index)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if bytes.Compare(left[:], right[:]) > 0 {
+		left, right = right, left
+	}
+	return taggedHash("TapBranch", left[:], right[:]), nil
+}
+
+// TapLeafHash returns the tapleaf hash of a Tapscript (BIP 341).
+func TapLeafHash(script []byte) [32]byte {
+	return taggedHash("TapLeaf", []byte{tapscriptLeafVersion}, compactSize(uint64(len(script))), script)
+}
+
+// compactSize returns the CompactSize encoding of n used in transactions.
+func compactSize(n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return []byte{byte(n)}
+	case n <= 0xffff:
+		return []byte{0xfd, byte(n), byte(n >> 8)}
+	case n <= 0xffffffff:
+		return []byte{0xfe, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24)}
+	}
+	return []byte{0xff, byte(n), byte(n >> 8), byte(n >> 16), byte(n >> 24), byte(n >> 32), byte(n >> 40), byte(n >> 48), byte(n >> 56)}
+}
+
+// taggedHash computes the tagged hash defined in BIP 340.
+func taggedHash(tag string, data ...[]byte) [32]byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	hash := sha256.New()
+	hash.Write(tagHash[:])
+	hash.Write(tagHash[:])
+	for _, d := range data {
+		hash.Write(d)
+	}
+	return [32]byte(hash.Sum(nil))
+}
+
+// Expand returns the output described by this Descriptor at index. The index is ignored if this Descriptor is not ranged.
+// WitnessScript is set for wsh(). ErrorMultipath of package descriptor is returned if this Descriptor has multipath keys.
+func (d *Descriptor) Expand(index uint32) (descriptor.Output, error) {
+	if n, _ := d.multipathLen(); n > 1 {
+		return descriptor.Output{}, descriptor.ErrorMultipath
+	}
+	if d.node != nil {
+		witnessScript, err := d.node.Script(index)
+		if err != nil {
+			return descriptor.Output{}, err
+		}
+		hash := sha256.Sum256(witnessScript)
+		scriptPubKey := new(descriptor.ScriptBuilder).AddOp(descriptor.OP_0).AddData(hash[:]).Script()
+		return descriptor.Output{ScriptPubKey: scriptPubKey, WitnessScript: witnessScript}, nil
+	}
+	internalKey, err := d.internalKey.PublicKey(index)
+	if err != nil {
+		return descriptor.Output{}, err
+	}
+	var merkleRoot []byte
+	if d.tree != nil {
+		root, err := d.tree.MerkleRoot(index)
+		if err != nil {
+			return descriptor.Output{}, err
+		}
+		merkleRoot = root[:]
+	}
+	outputKey, err := descriptor.TaprootOutputKey(internalKey, merkleRoot)
+	if err != nil {
+		return descriptor.Output{}, err
+	}
+	scriptPubKey := new(descriptor.ScriptBuilder).AddOp(descriptor.OP_1).AddData(outputKey[:]).Script()
+	return descriptor.Output{ScriptPubKey: scriptPubKey}, nil
+}
+
+// Address returns the address of the output described by this Descriptor at index on network.
+func (d *Descriptor) Address(index uint32, network descriptor.Network) (string, error) {
+	output, err := d.Expand(index)
+	if err != nil {
+		return "", err
+	}
+	return descriptor.Address(output.ScriptPubKey, network)
+}
@@ -0,0 +1,497 @@
+// Package miniscript parses, type-checks and encodes Miniscript (BIP 379) over descriptor KEY expressions.
+//
+// Expressions are parsed in either the P2WSH or the Tapscript context, and descriptors wsh(MINISCRIPT)
+// and tr(KEY,TREE) are supported by ParseDescriptor. Keys may be ranged, so a Node encodes to a different script at each index.
+// Resource limits (the number of opcodes and the stack size) are not checked.
+//
+// Spec: https://github.com/bitcoin/bips/blob/master/bip-0379.md
+// Reference: https://bitcoin.sipa.be/miniscript/
+package miniscript
+
+import (
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/koba-e964/bip32-typesafe/descriptor"
+)
+
+var (
+	ErrorInvalidExpression = errors.New("miniscript expression is invalid")
+	ErrorTypeCheck         = errors.New("miniscript expression doesn't type-check")
+	ErrorInvalidThreshold  = errors.New("threshold is invalid")
+	ErrorInvalidTimelock   = errors.New("timelock is invalid")
+	ErrorInvalidHash       = errors.New("hash has an invalid length")
+	ErrorTooManyKeys       = errors.New("too many keys in multi")
+	ErrorContext           = errors.New("fragment is not allowed in this context")
+	ErrorNotSane           = errors.New("miniscript is not sane")
+	ErrorTreeTooDeep       = errors.New("taproot tree is too deep")
+)
+
+// Context is the script context in which a miniscript expression is used.
+type Context int
+
+const (
+	ContextP2WSH Context = iota
+	ContextTapscript
+)
+
+type fragment int
+
+const (
+	fragment0 fragment = iota
+	fragment1
+	fragmentPkK
+	fragmentPkH
+	fragmentOlder
+	fragmentAfter
+	fragmentSha256
+	fragmentHash256
+	fragmentRipemd160
+	fragmentHash160
+	fragmentMulti
+	fragmentMultiA
+	fragmentWrapA
+	fragmentWrapS
+	fragmentWrapC
+	fragmentWrapD
+	fragmentWrapV
+	fragmentWrapJ
+	fragmentWrapN
+	fragmentAndV
+	fragmentAndB
+	fragmentOrB
+	fragmentOrC
+	fragmentOrD
+	fragmentOrI
+	fragmentAndOr
+	fragmentThresh
+)
+
+var fragmentNames = map[fragment]string{
+	fragment0:         "0",
+	fragment1:         "1",
+	fragmentPkK:       "pk_k",
+	fragmentPkH:       "pk_h",
+	fragmentOlder:     "older",
+	fragmentAfter:     "after",
+	fragmentSha256:    "sha256",
+	fragmentHash256:   "hash256",
+	fragmentRipemd160: "ripemd160",
+	fragmentHash160:   "hash160",
+	fragmentMulti:     "multi",
+	fragmentMultiA:    "multi_a",
+	fragmentAndV:      "and_v",
+	fragmentAndB:      "and_b",
+	fragmentOrB:       "or_b",
+	fragmentOrC:       "or_c",
+	fragmentOrD:       "or_d",
+	fragmentOrI:       "or_i",
+	fragmentAndOr:     "andor",
+	fragmentThresh:    "thresh",
+}
+
+// wrappers maps wrapper letters to fragments. t, l and u are syntactic sugar and handled separately.
+var wrappers = map[byte]fragment{
+	'a': fragmentWrapA,
+	's': fragmentWrapS,
+	'c': fragmentWrapC,
+	'd': fragmentWrapD,
+	'v': fragmentWrapV,
+	'j': fragmentWrapJ,
+	'n': fragmentWrapN,
+}
+
+const (
+	// maximum numbers of keys in multi and multi_a
+	maxMultiKeys  = 20
+	maxMultiAKeys = 999
+
+	// BIP 68 and BIP 65
+	sequenceLocktimeTypeFlag = 1 << 22
+	locktimeThreshold        = 500000000
+)
+
+// Node is a parsed miniscript expression.
+type Node struct {
+	fragment fragment
+	ctx      Context
+	k        uint32 // threshold of multi, multi_a and thresh, or the value of older and after
+	keys     []*descriptor.Key
+	hash     []byte
+	subs     []*Node
+	typ      Type
+}
+
+// Parse parses a miniscript expression in ctx and type-checks it. The expression must be of type B.
+// It doesn't check whether the expression is sane; see IsSane.
+func Parse(s string, ctx Context) (*Node, error) {
+	n, err := parse(s, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !n.typ.Has(TypeB) {
+		return nil, ErrorTypeCheck
+	}
+	return n, nil
+}
+
+func parse(s string, ctx Context) (*Node, error) {
+	name := s
+	if open := strings.IndexByte(s, '('); open >= 0 {
+		name = s[:open]
+	}
+	if colon := strings.IndexByte(name, ':'); colon >= 0 {
+		letters := name[:colon]
+		if letters == "" {
+			return nil, ErrorInvalidExpression
+		}
+		n, err := parse(s[colon+1:], ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := len(letters) - 1; i >= 0; i-- {
+			switch letters[i] {
+			case 't':
+				n, err = newNode(ctx, fragmentAndV, n, constant(ctx, fragment1))
+			case 'l':
+				n, err = newNode(ctx, fragmentOrI, constant(ctx, fragment0), n)
+			case 'u':
+				n, err = newNode(ctx, fragmentOrI, n, constant(ctx, fragment0))
+			default:
+				wrapper, ok := wrappers[letters[i]]
+				if !ok {
+					return nil, ErrorInvalidExpression
+				}
+				n, err = newNode(ctx, wrapper, n)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	}
+	switch s {
+	case "0":
+		return constant(ctx, fragment0), nil
+	case "1":
+		return constant(ctx, fragment1), nil
+	}
+	if !strings.HasSuffix(s, ")") || name == s {
+		return nil, ErrorInvalidExpression
+	}
+	args, err := descriptor.SplitArgs(s[len(name)+1 : len(s)-1])
+	if err != nil {
+		return nil, ErrorInvalidExpression
+	}
+	switch name {
+	case "pk", "pkh", "pk_k", "pk_h":
+		if len(args) != 1 {
+			return nil, ErrorInvalidExpression
+		}
+		key, err := parseKey(args[0], ctx)
+		if err != nil {
+			return nil, err
+		}
+		f := fragmentPkK
+		if name == "pkh" || name == "pk_h" {
+			f = fragmentPkH
+		}
+		n, err := finish(&Node{fragment: f, ctx: ctx, keys: []*descriptor.Key{key}})
+		if err != nil || name == "pk_k" || name == "pk_h" {
+			return n, err
+		}
+		return newNode(ctx, fragmentWrapC, n)
+	case "older", "after":
+		if len(args) != 1 {
+			return nil, ErrorInvalidExpression
+		}
+		value, err := strconv.ParseUint(args[0], 10, 32)
+		if err != nil || value < 1 || value >= 1<<31 {
+			return nil, ErrorInvalidTimelock
+		}
+		f := fragmentOlder
+		if name == "after" {
+			f = fragmentAfter
+		}
+		return finish(&Node{fragment: f, ctx: ctx, k: uint32(value)})
+	case "sha256", "hash256", "ripemd160", "hash160":
+		fragments := map[string]fragment{"sha256": fragmentSha256, "hash256": fragmentHash256, "ripemd160": fragmentRipemd160, "hash160": fragmentHash160}
+		if len(args) != 1 {
+			return nil, ErrorInvalidExpression
+		}
+		hash, err := hex.DecodeString(args[0])
+		if err != nil {
+			return nil, ErrorInvalidExpression
+		}
+		f := fragments[name]
+		expectedLength := 32
+		if f == fragmentRipemd160 || f == fragmentHash160 {
+			expectedLength = 20
+		}
+		if len(hash) != expectedLength {
+			return nil, ErrorInvalidHash
+		}
+		return finish(&Node{fragment: f, ctx: ctx, hash: hash})
+	case "multi", "multi_a":
+		f, limit := fragmentMulti, maxMultiKeys
+		if name == "multi_a" {
+			f, limit = fragmentMultiA, maxMultiAKeys
+		}
+		if (f == fragmentMulti) != (ctx == ContextP2WSH) {
+			return nil, ErrorContext
+		}
+		if len(args) < 2 {
+			return nil, ErrorInvalidExpression
+		}
+		if len(args)-1 > limit {
+			return nil, ErrorTooManyKeys
+		}
+		threshold, err := parseThreshold(args[0], len(args)-1)
+		if err != nil {
+			return nil, err
+		}
+		n := Node{fragment: f, ctx: ctx, k: threshold}
+		for _, arg := range args[1:] {
+			key, err := parseKey(arg, ctx)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key)
+		}
+		return finish(&n)
+	case "thresh":
+		if len(args) < 2 {
+			return nil, ErrorInvalidExpression
+		}
+		threshold, err := parseThreshold(args[0], len(args)-1)
+		if err != nil {
+			return nil, err
+		}
+		subs, err := parseSubs(args[1:], ctx)
+		if err != nil {
+			return nil, err
+		}
+		return finish(&Node{fragment: fragmentThresh, ctx: ctx, k: threshold, subs: subs})
+	case "and_n":
+		if len(args) != 2 {
+			return nil, ErrorInvalidExpression
+		}
+		subs, err := parseSubs(args, ctx)
+		if err != nil {
+			return nil, err
+		}
+		return newNode(ctx, fragmentAndOr, subs[0], subs[1], constant(ctx, fragment0))
+	}
+	combinators := map[string]fragment{
+		"and_v": fragmentAndV, "and_b": fragmentAndB, "andor": fragmentAndOr,
+		"or_b": fragmentOrB, "or_c": fragmentOrC, "or_d": fragmentOrD, "or_i": fragmentOrI,
+	}
+	f, ok := combinators[name]
+	arity := 2
+	if f == fragmentAndOr {
+		arity = 3
+	}
+	if !ok || len(args) != arity {
+		return nil, ErrorInvalidExpression
+	}
+	subs, err := parseSubs(args, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newNode(ctx, f, subs...)
+}
+
+func parseSubs(args []string, ctx Context) ([]*Node, error) {
+	subs := make([]*Node, len(args))
+	for i, arg := range args {
+		sub, err := parse(arg, ctx)
+		if err != nil {
+			return nil, err
+		}
+		subs[i] = sub
+	}
+	return subs, nil
+}
+
+// parseThreshold parses k in multi, multi_a and thresh, which must satisfy 1 <= k <= n.
+func parseThreshold(s string, n int) (uint32, error) {
+	k, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || k < 1 || k > uint64(n) {
+		return 0, ErrorInvalidThreshold
+	}
+	return uint32(k), nil
+}
+
+// parseKey parses a KEY expression. x-only keys are allowed only in Tapscript.
+func parseKey(s string, ctx Context) (*descriptor.Key, error) {
+	key, err := descriptor.ParseKey(s)
+	if err != nil {
+		return nil, err
+	}
+	if key.IsXOnly() && ctx != ContextTapscript {
+		return nil, descriptor.ErrorInvalidKey
+	}
+	return key, nil
+}
+
+// constant returns 0 or 1.
+func constant(ctx Context, f fragment) *Node {
+	n := Node{fragment: f, ctx: ctx}
+	n.typ = computeType(&n)
+	return &n
+}
+
+// newNode creates a node with sub-expressions and type-checks it.
+func newNode(ctx Context, f fragment, subs ...*Node) (*Node, error) {
+	return finish(&Node{fragment: f, ctx: ctx, subs: subs})
+}
+
+// finish computes the type of n and returns ErrorTypeCheck if it is invalid.
+func finish(n *Node) (*Node, error) {
+	n.typ = computeType(n)
+	if !n.typ.IsValid() {
+		return nil, ErrorTypeCheck
+	}
+	return n, nil
+}
+
+// Type returns the type of this Node.
+func (n *Node) Type() Type {
+	return n.typ
+}
+
+// Context returns the context in which this Node was parsed.
+func (n *Node) Context() Context {
+	return n.ctx
+}
+
+// Keys returns all KEY expressions in this Node in the order of appearance.
+func (n *Node) Keys() []*descriptor.Key {
+	result := append([]*descriptor.Key(nil), n.keys...)
+	for _, sub := range n.subs {
+		result = append(result, sub.Keys()...)
+	}
+	return result
+}
+
+// IsNonMalleable returns true if a non-malleable satisfaction always exists.
+func (n *Node) IsNonMalleable() bool {
+	return n.typ.Has(PropM)
+}
+
+// NeedsSignature returns true if every satisfaction requires a signature.
+func (n *Node) NeedsSignature() bool {
+	return n.typ.Has(PropS)
+}
+
+// HasTimelockMix returns true if a satisfaction may require both a height-based and a time-based timelock of the same kind,
+// which can't be satisfied at once.
+func (n *Node) HasTimelockMix() bool {
+	return !n.typ.Has(PropK)
+}
+
+// HasDuplicateKeys returns true if a KEY expression appears more than once.
+func (n *Node) HasDuplicateKeys() bool {
+	seen := map[string]bool{}
+	for _, key := range n.Keys() {
+		s := key.String()
+		if seen[s] {
+			return true
+		}
+		seen[s] = true
+	}
+	return false
+}
+
+// IsSane returns true if this Node is of type B, non-malleable, requires a signature,
+// has no timelock mixes and has no duplicate keys. Only sane expressions are accepted in descriptors.
+func (n *Node) IsSane() bool {
+	return n.typ.Has(TypeB) && n.IsNonMalleable() && n.NeedsSignature() && !n.HasTimelockMix() && !n.HasDuplicateKeys()
+}
+
+// String returns the miniscript expression, using syntactic sugar (pk, pkh, and_n, t:, l: and u:) where possible.
+func (n *Node) String() string {
+	wrapperLetters, body := n.split()
+	if wrapperLetters == "" {
+		return body
+	}
+	return wrapperLetters + ":" + body
+}
+
+// split returns the wrapper letters of n and the expression they wrap.
+func (n *Node) split() (string, string) {
+	switch n.fragment {
+	case fragmentWrapC:
+		switch n.subs[0].fragment {
+		case fragmentPkK:
+			return "", "pk(" + n.subs[0].keys[0].String() + ")"
+		case fragmentPkH:
+			return "", "pkh(" + n.subs[0].keys[0].String() + ")"
+		}
+		wrapperLetters, body := n.subs[0].split()
+		return "c" + wrapperLetters, body
+	case fragmentWrapA, fragmentWrapS, fragmentWrapD, fragmentWrapV, fragmentWrapJ, fragmentWrapN:
+		wrapperLetters, body := n.subs[0].split()
+		for letter, wrapper := range wrappers {
+			if wrapper == n.fragment {
+				return string(letter) + wrapperLetters, body
+			}
+		}
+	case fragmentAndV:
+		if n.subs[1].fragment == fragment1 {
+			wrapperLetters, body := n.subs[0].split()
+			return "t" + wrapperLetters, body
+		}
+	case fragmentOrI:
+		if n.subs[0].fragment == fragment0 {
+			wrapperLetters, body := n.subs[1].split()
+			return "l" + wrapperLetters, body
+		}
+		if n.subs[1].fragment == fragment0 {
+			wrapperLetters, body := n.subs[0].split()
+			return "u" + wrapperLetters, body
+		}
+	case fragmentAndOr:
+		if n.subs[2].fragment == fragment0 {
+			return "", "and_n(" + n.subs[0].String() + "," + n.subs[1].String() + ")"
+		}
+	}
+	var builder strings.Builder
+	builder.WriteString(fragmentNames[n.fragment])
+	switch n.fragment {
+	case fragment0, fragment1:
+		return "", builder.String()
+	}
+	builder.WriteByte('(')
+	switch n.fragment {
+	case fragmentOlder, fragmentAfter:
+		builder.WriteString(strconv.FormatUint(uint64(n.k), 10))
+	case fragmentSha256, fragmentHash256, fragmentRipemd160, fragmentHash160:
+		builder.WriteString(hex.EncodeToString(n.hash))
+	case fragmentPkK, fragmentPkH:
+		builder.WriteString(n.keys[0].String())
+	case fragmentMulti, fragmentMultiA:
+		builder.WriteString(strconv.FormatUint(uint64(n.k), 10))
+		for _, key := range n.keys {
+			builder.WriteByte(',')
+			builder.WriteString(key.String())
+		}
+	case fragmentThresh:
+		builder.WriteString(strconv.FormatUint(uint64(n.k), 10))
+		for _, sub := range n.subs {
+			builder.WriteByte(',')
+			builder.WriteString(sub.String())
+		}
+	default:
+		for i, sub := range n.subs {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+			builder.WriteString(sub.String())
+		}
+	}
+	builder.WriteByte(')')
+	return "", builder.String()
+}
@@ -0,0 +1,211 @@
+package miniscript
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/koba-e964/bip32-typesafe/descriptor"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	key1 = "03d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a"
+	key2 = "025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7cc"
+	key3 = "03d30199d74fb5a22d47b6e054e2f378cedacffcb89904a61d75d0dbd407143e65"
+
+	// account-level key at m/84'/0'/0' derived from the seed of "abandon abandon ... about"
+	bip84Xpub = "xpub6CatWdiZiodmUeTDp8LT5or8nmbKNcuyvz7WyksVFkKB4RHwCD3XyuvPEbvqAQY3rAPshWcMLoP2fMFMKHPJ4ZeZXYVUhLv1VMrjPC7PW6V"
+)
+
+func TestScript(t *testing.T) {
+	tests := []struct {
+		ms     string
+		script string
+	}{
+		// https://github.com/bitcoin/bitcoin/blob/v27.0/src/test/miniscript_tests.cpp
+		{ms: "lltvln:after(1231488000)", script: "6300676300676300670400046749b1926869516868"},
+		{ms: "uuj:and_v(v:multi(2," + key1 + "," + key2 + "),after(1231488000))", script: "6363829263522103d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a21025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7cc52af0400046749b168670068670068"},
+		{ms: "j:and_v(vdv:after(1567547623),older(2016))", script: "829263766304e7e06e5db169686902e007b268"},
+		{ms: "t:and_v(vu:hash256(131772552c01444cd81360818376a040b7c3b2b7b0a53550ee3edde216cec61b),v:sha256(ec4916dd28fc4c10d78e287ca5d9cc51ee1ae73cbfde08c6b37324cbfaac8bc5))", script: "6382012088aa20131772552c01444cd81360818376a040b7c3b2b7b0a53550ee3edde216cec61b876700686982012088a820ec4916dd28fc4c10d78e287ca5d9cc51ee1ae73cbfde08c6b37324cbfaac8bc58851"},
+		{ms: "or_b(un:multi(2," + key1 + "," + key2 + "),al:older(16))", script: "63522103d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85a21025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7cc52ae926700686b63006760b2686c9b"},
+		{ms: "and_n(pk(" + key1 + "),older(1))", script: "2103d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85aac64006751b268"},
+		{ms: "thresh(2,pk(" + key1 + "),s:pk(" + key2 + "),sln:older(1))", script: "2103d01115d548e7561b15c38f004d734633687cf4419620095bc5b0f47070afe85aac7c21025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7ccac937c63006751b29268935287"},
+		{ms: "or_d(pkh(" + key1 + "),and_v(v:pk(" + key2 + "),older(144)))", script: "76a914" + hex.EncodeToString(descriptor.Hash160(mustDecodeHex(key1))) + "88ac736421025601570cb47f238d2b0286db4a990fa0f3ba28d1a319f5e7cf55c2a2444da7ccad029000b268"},
+	}
+	for _, test := range tests {
+		n, err := Parse(test.ms, ContextP2WSH)
+		if !assert.Nil(t, err, test.ms) {
+			continue
+		}
+		script, err := n.Script(0)
+		assert.Nil(t, err)
+		assert.Equal(t, test.script, hex.EncodeToString(script), test.ms)
+		assert.Equal(t, test.ms, n.String())
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	data, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+func TestTypeCheck(t *testing.T) {
+	tests := []struct {
+		ms  string
+		err error
+	}{
+		{ms: "and_v(pk(" + key1 + "),pk(" + key2 + "))", err: ErrorTypeCheck},
+		{ms: "c:after(10)", err: ErrorTypeCheck},
+		{ms: "pk_k(" + key1 + ")", err: ErrorTypeCheck},
+		{ms: "v:pk(" + key1 + ")", err: ErrorTypeCheck},
+		{ms: "older(0)", err: ErrorInvalidTimelock},
+		{ms: "after(2147483648)", err: ErrorInvalidTimelock},
+		{ms: "thresh(0,pk(" + key1 + "))", err: ErrorInvalidThreshold},
+		{ms: "multi(3," + key1 + "," + key2 + ")", err: ErrorInvalidThreshold},
+		{ms: "sha256(0011)", err: ErrorInvalidHash},
+		{ms: "multi_a(1," + key1 + ")", err: ErrorContext},
+		{ms: "pk(" + key1[2:] + ")", err: descriptor.ErrorInvalidKey},
+		{ms: "x:pk(" + key1 + ")", err: ErrorInvalidExpression},
+		{ms: "and_v(pk(" + key1 + "))", err: ErrorInvalidExpression},
+	}
+	for _, test := range tests {
+		_, err := Parse(test.ms, ContextP2WSH)
+		assert.Equal(t, test.err, err, test.ms)
+	}
+	_, err := Parse("multi(1,"+key1+")", ContextTapscript)
+	assert.Equal(t, ErrorContext, err)
+}
+
+func TestType(t *testing.T) {
+	n, err := Parse("pk("+key1+")", ContextP2WSH)
+	assert.Nil(t, err)
+	assert.Equal(t, "Bondusemk", sortedType(n.Type()))
+	// d: is unit only in Tapscript
+	n, err = Parse("dv:older(1)", ContextP2WSH)
+	assert.Nil(t, err)
+	assert.False(t, n.Type().Has(PropU))
+	n, err = Parse("dv:older(1)", ContextTapscript)
+	assert.Nil(t, err)
+	assert.True(t, n.Type().Has(PropU))
+}
+
+// sortedType returns the letters of t in the order of "BVKWzondusemk" restricted to the bits that are set, for readability in tests.
+func sortedType(t Type) string {
+	result := ""
+	for _, letter := range "BVKWzondusemk" {
+		if t.Has(mst(string(letter))) {
+			result += string(letter)
+		}
+	}
+	return result
+}
+
+func TestSanity(t *testing.T) {
+	tests := []struct {
+		ms             string
+		nonMalleable   bool
+		needsSignature bool
+		timelockMix    bool
+		duplicateKeys  bool
+	}{
+		{ms: "pk(" + key1 + ")", nonMalleable: true, needsSignature: true},
+		// no signature is needed to satisfy hashlocks
+		{ms: "sha256(ec4916dd28fc4c10d78e287ca5d9cc51ee1ae73cbfde08c6b37324cbfaac8bc5)", nonMalleable: true},
+		// a third party can choose either branch
+		{ms: "or_i(older(1),older(2))"},
+		{ms: "and_b(older(1),a:older(4194305))", nonMalleable: true, timelockMix: true},
+		{ms: "and_v(v:pk(" + key1 + "),pk(" + key1 + "))", nonMalleable: true, needsSignature: true, duplicateKeys: true},
+		{ms: "or_d(pk(" + key1 + "),and_v(v:pk(" + key2 + "),older(144)))", nonMalleable: true, needsSignature: true},
+	}
+	for _, test := range tests {
+		n, err := Parse(test.ms, ContextP2WSH)
+		if !assert.Nil(t, err, test.ms) {
+			continue
+		}
+		assert.Equal(t, test.nonMalleable, n.IsNonMalleable(), test.ms)
+		assert.Equal(t, test.needsSignature, n.NeedsSignature(), test.ms)
+		assert.Equal(t, test.timelockMix, n.HasTimelockMix(), test.ms)
+		assert.Equal(t, test.duplicateKeys, n.HasDuplicateKeys(), test.ms)
+		assert.Equal(t, test.nonMalleable && test.needsSignature && !test.timelockMix && !test.duplicateKeys, n.IsSane(), test.ms)
+	}
+}
+
+func TestDescriptor(t *testing.T) {
+	// wsh(multi) agrees with package descriptor
+	desc := "wsh(multi(1," + bip84Xpub + "/0/*," + key2 + "))"
+	d, err := ParseDescriptor(desc)
+	assert.Nil(t, err)
+	expected, err := descriptor.Parse(desc)
+	assert.Nil(t, err)
+	for index := uint32(0); index < 3; index++ {
+		output, err := d.Expand(index)
+		assert.Nil(t, err)
+		expectedOutputs, err := expected.Expand(index)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedOutputs[0], output)
+	}
+	assert.Equal(t, expected.String(), d.String())
+	assert.True(t, d.IsRange())
+
+	// tr() without a tree agrees with package descriptor
+	desc = "tr(" + key1 + ")"
+	d, err = ParseDescriptor(desc)
+	assert.Nil(t, err)
+	expected, err = descriptor.Parse(desc)
+	assert.Nil(t, err)
+	address, err := d.Address(0, descriptor.Mainnet)
+	assert.Nil(t, err)
+	expectedAddresses, err := expected.Addresses(0, descriptor.Mainnet)
+	assert.Nil(t, err)
+	assert.Equal(t, expectedAddresses[0], address)
+
+	_, err = ParseDescriptor("wsh(sha256(ec4916dd28fc4c10d78e287ca5d9cc51ee1ae73cbfde08c6b37324cbfaac8bc5))")
+	assert.Equal(t, ErrorNotSane, err)
+	_, err = ParseDescriptor("wsh(pk(" + key1[2:] + "))")
+	assert.Equal(t, descriptor.ErrorInvalidKey, err)
+	_, err = ParseDescriptor("tr(" + key1 + ",multi(1," + key2 + "))")
+	assert.Equal(t, ErrorContext, err)
+}
+
+func TestTaprootTree(t *testing.T) {
+	desc := "tr(" + key1 + ",{pk(" + key2[2:] + "),{multi_a(1," + key2 + "," + key3 + "),and_v(v:pk(" + key3 + "),older(144))}})"
+	d, err := ParseDescriptor(desc)
+	if !assert.Nil(t, err) {
+		return
+	}
+	checksum, err := descriptor.Checksum(desc)
+	assert.Nil(t, err)
+	assert.Equal(t, desc+"#"+checksum, d.String())
+	leaf, err := d.TapTree().Left.Leaf.Script(0)
+	assert.Nil(t, err)
+	assert.Equal(t, "20"+key2[2:]+"ac", hex.EncodeToString(leaf))
+	leaf, err = d.TapTree().Right.Left.Leaf.Script(0)
+	assert.Nil(t, err)
+	assert.Equal(t, "20"+key2[2:]+"ac20"+key3[2:]+"ba519c", hex.EncodeToString(leaf))
+	address, err := d.Address(0, descriptor.Mainnet)
+	assert.Nil(t, err)
+	// computed independently with a Python implementation of BIP 341
+	assert.Equal(t, "bc1p7sqt445mueu5kgyq7yfymy62p25fzc928mms3qx0a7e5ek5nuk9qv39p6h", address)
+}
+
+func TestDescriptorMultipath(t *testing.T) {
+	d, err := ParseDescriptor("wsh(and_v(v:pk(" + bip84Xpub + "/<0;1>/*),older(144)))")
+	assert.Nil(t, err)
+	_, err = d.Expand(0)
+	assert.Equal(t, descriptor.ErrorMultipath, err)
+	descriptors := d.Descriptors()
+	assert.Equal(t, 2, len(descriptors))
+	for i, single := range descriptors {
+		expected, err := ParseDescriptor("wsh(and_v(v:pk(" + bip84Xpub + "/" + string(rune('0'+i)) + "/*),older(144)))")
+		assert.Nil(t, err)
+		assert.Equal(t, expected.String(), single.String())
+		output, err := single.Expand(5)
+		assert.Nil(t, err)
+		expectedOutput, err := expected.Expand(5)
+		assert.Nil(t, err)
+		assert.Equal(t, expectedOutput, output)
+	}
+}
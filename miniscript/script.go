@@ -0,0 +1,233 @@
+package miniscript
+
+import (
+	"github.com/koba-e964/bip32-typesafe/descriptor"
+)
+
+// Opcodes used in miniscript, in addition to those in package descriptor.
+const (
+	opIf                  = 0x63
+	opNotIf               = 0x64
+	opElse                = 0x67
+	opEndIf               = 0x68
+	opVerify              = 0x69
+	opToAltStack          = 0x6b
+	opFromAltStack        = 0x6c
+	opIfDup               = 0x73
+	opSwap                = 0x7c
+	opSize                = 0x82
+	op0NotEqual           = 0x92
+	opAdd                 = 0x93
+	opBoolAnd             = 0x9a
+	opBoolOr              = 0x9b
+	opNumEqual            = 0x9c
+	opNumEqualVerify      = 0x9d
+	opRipemd160           = 0xa6
+	opSha256              = 0xa8
+	opHash256             = 0xaa
+	opCheckSigVerify      = 0xad
+	opCheckMultiSigVerify = 0xaf
+	opCheckLockTimeVerify = 0xb1
+	opCheckSequenceVerify = 0xb2
+	opCheckSigAdd         = 0xba
+)
+
+var hashOps = map[fragment]byte{
+	fragmentSha256:    opSha256,
+	fragmentHash256:   opHash256,
+	fragmentRipemd160: opRipemd160,
+	fragmentHash160:   descriptor.OP_HASH160,
+}
+
+// Script returns the script of this Node at index (which is used only for ranged keys).
+// In Tapscript, keys are serialized as x-only public keys.
+func (n *Node) Script(index uint32) ([]byte, error) {
+	builder := new(descriptor.ScriptBuilder)
+	if err := n.encode(builder, index, false); err != nil {
+		return nil, err
+	}
+	return builder.Script(), nil
+}
+
+// publicKey returns the serialization of key at index in the context of n.
+func (n *Node) publicKey(key *descriptor.Key, index uint32) ([]byte, error) {
+	publicKey, err := key.PublicKey(index)
+	if err != nil {
+		return nil, err
+	}
+	if n.ctx == ContextTapscript {
+		return publicKey[1:], nil
+	}
+	return publicKey[:], nil
+}
+
+// encode appends the script of n to builder.
+// If verify is true, n is followed by OP_VERIFY, which n merges into its last opcode (only if n doesn't have the property x).
+func (n *Node) encode(builder *descriptor.ScriptBuilder, index uint32, verify bool) error {
+	equal := byte(descriptor.OP_EQUAL)
+	if verify {
+		equal = descriptor.OP_EQUALVERIFY
+	}
+	switch n.fragment {
+	case fragment0:
+		builder.AddOp(descriptor.OP_0)
+	case fragment1:
+		builder.AddOp(descriptor.OP_1)
+	case fragmentPkK:
+		publicKey, err := n.publicKey(n.keys[0], index)
+		if err != nil {
+			return err
+		}
+		builder.AddData(publicKey)
+	case fragmentPkH:
+		publicKey, err := n.publicKey(n.keys[0], index)
+		if err != nil {
+			return err
+		}
+		builder.AddOp(descriptor.OP_DUP, descriptor.OP_HASH160).AddData(descriptor.Hash160(publicKey)).AddOp(descriptor.OP_EQUALVERIFY)
+	case fragmentOlder:
+		builder.AddInt(int64(n.k)).AddOp(opCheckSequenceVerify)
+	case fragmentAfter:
+		builder.AddInt(int64(n.k)).AddOp(opCheckLockTimeVerify)
+	case fragmentSha256, fragmentHash256, fragmentRipemd160, fragmentHash160:
+		builder.AddOp(opSize).AddInt(32).AddOp(descriptor.OP_EQUALVERIFY, hashOps[n.fragment]).AddData(n.hash).AddOp(equal)
+	case fragmentMulti:
+		builder.AddInt(int64(n.k))
+		for _, key := range n.keys {
+			publicKey, err := n.publicKey(key, index)
+			if err != nil {
+				return err
+			}
+			builder.AddData(publicKey)
+		}
+		builder.AddInt(int64(len(n.keys)))
+		if verify {
+			builder.AddOp(opCheckMultiSigVerify)
+		} else {
+			builder.AddOp(descriptor.OP_CHECKMULTISIG)
+		}
+	case fragmentMultiA:
+		for i, key := range n.keys {
+			publicKey, err := n.publicKey(key, index)
+			if err != nil {
+				return err
+			}
+			builder.AddData(publicKey)
+			if i == 0 {
+				builder.AddOp(descriptor.OP_CHECKSIG)
+			} else {
+				builder.AddOp(opCheckSigAdd)
+			}
+		}
+		builder.AddInt(int64(n.k))
+		if verify {
+			builder.AddOp(opNumEqualVerify)
+		} else {
+			builder.AddOp(opNumEqual)
+		}
+	case fragmentWrapA:
+		builder.AddOp(opToAltStack)
+		if err := n.subs[0].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(opFromAltStack)
+	case fragmentWrapS:
+		builder.AddOp(opSwap)
+		return n.subs[0].encode(builder, index, verify)
+	case fragmentWrapC:
+		if err := n.subs[0].encode(builder, index, false); err != nil {
+			return err
+		}
+		if verify {
+			builder.AddOp(opCheckSigVerify)
+		} else {
+			builder.AddOp(descriptor.OP_CHECKSIG)
+		}
+	case fragmentWrapD:
+		builder.AddOp(descriptor.OP_DUP, opIf)
+		if err := n.subs[0].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(opEndIf)
+	case fragmentWrapV:
+		if err := n.subs[0].encode(builder, index, true); err != nil {
+			return err
+		}
+		if n.subs[0].typ.Has(PropX) {
+			builder.AddOp(opVerify)
+		}
+	case fragmentWrapJ:
+		builder.AddOp(opSize, op0NotEqual, opIf)
+		if err := n.subs[0].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(opEndIf)
+	case fragmentWrapN:
+		if err := n.subs[0].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(op0NotEqual)
+	case fragmentAndV:
+		if err := n.subs[0].encode(builder, index, false); err != nil {
+			return err
+		}
+		return n.subs[1].encode(builder, index, verify)
+	case fragmentAndB, fragmentOrB:
+		for _, sub := range n.subs {
+			if err := sub.encode(builder, index, false); err != nil {
+				return err
+			}
+		}
+		if n.fragment == fragmentAndB {
+			builder.AddOp(opBoolAnd)
+		} else {
+			builder.AddOp(opBoolOr)
+		}
+	case fragmentOrC, fragmentOrD:
+		if err := n.subs[0].encode(builder, index, false); err != nil {
+			return err
+		}
+		if n.fragment == fragmentOrD {
+			builder.AddOp(opIfDup)
+		}
+		builder.AddOp(opNotIf)
+		if err := n.subs[1].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(opEndIf)
+	case fragmentOrI:
+		builder.AddOp(opIf)
+		if err := n.subs[0].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(opElse)
+		if err := n.subs[1].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(opEndIf)
+	case fragmentAndOr:
+		if err := n.subs[0].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(opNotIf)
+		if err := n.subs[2].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(opElse)
+		if err := n.subs[1].encode(builder, index, false); err != nil {
+			return err
+		}
+		builder.AddOp(opEndIf)
+	case fragmentThresh:
+		for i, sub := range n.subs {
+			if err := sub.encode(builder, index, false); err != nil {
+				return err
+			}
+			if i > 0 {
+				builder.AddOp(opAdd)
+			}
+		}
+		builder.AddInt(int64(n.k)).AddOp(equal)
+	}
+	return nil
+}
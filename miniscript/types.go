@@ -0,0 +1,245 @@
+package miniscript
+
+import "strings"
+
+// Type is the type of a miniscript expression: a basic type (B, V, K or W) together with its properties.
+// It is a bit set; see https://bitcoin.sipa.be/miniscript/ for the meaning of each bit.
+type Type uint32
+
+const (
+	TypeB Type = 1 << iota // base expression
+	TypeV                  // verify expression
+	TypeK                  // key expression
+	TypeW                  // wrapped expression
+
+	PropZ // zero-arg: always consumes exactly 0 stack elements
+	PropO // one-arg: always consumes exactly 1 stack element
+	PropN // nonzero: never needs a zero top stack element to satisfy
+	PropD // dissatisfiable: a dissatisfaction can be constructed without signatures
+	PropU // unit: puts exactly 1 on the stack when satisfied
+
+	PropE // expressive: the dissatisfaction is unique and requires a signature to be malleated
+	PropF // forced: dissatisfactions always involve a signature
+	PropS // safe: satisfactions always involve a signature
+	PropM // nonmalleable: a non-malleable satisfaction always exists
+	PropX // expensive verify: the last opcode is not EQUAL, CHECKSIG, CHECKMULTISIG or NUMEQUAL
+
+	PropG // contains a relative time timelock
+	PropH // contains a relative height timelock
+	PropI // contains an absolute time timelock
+	PropJ // contains an absolute height timelock
+	PropK // doesn't mix heights and times in timelocks that can be required at the same time
+)
+
+// typeLetters are the letters of the bits of Type, in order.
+const typeLetters = "BVKWzonduefsmxghijk"
+
+// mst converts a string of type letters to a Type, like "Bzud".
+func mst(letters string) Type {
+	var result Type
+	for i := 0; i < len(letters); i++ {
+		pos := strings.IndexByte(typeLetters, letters[i])
+		result |= 1 << pos
+	}
+	return result
+}
+
+// Has returns true if t has all bits in other.
+func (t Type) Has(other Type) bool {
+	return t&other == other
+}
+
+// when returns t if cond holds, and 0 otherwise.
+func (t Type) when(cond bool) Type {
+	if cond {
+		return t
+	}
+	return 0
+}
+
+// IsValid returns true if t has exactly one basic type.
+func (t Type) IsValid() bool {
+	basic := t & (TypeB | TypeV | TypeK | TypeW)
+	return basic != 0 && basic&(basic-1) == 0
+}
+
+// String returns the letters of the bits in t, such as "Bzud".
+func (t Type) String() string {
+	var builder strings.Builder
+	for i := 0; i < len(typeLetters); i++ {
+		if t&(1<<i) != 0 {
+			builder.WriteByte(typeLetters[i])
+		}
+	}
+	return builder.String()
+}
+
+// timelockMix returns true if x and y have different kinds of timelocks of the same class (relative or absolute).
+func timelockMix(x Type, y Type) bool {
+	return (x.Has(PropG) && y.Has(PropH)) ||
+		(x.Has(PropH) && y.Has(PropG)) ||
+		(x.Has(PropI) && y.Has(PropJ)) ||
+		(x.Has(PropJ) && y.Has(PropI))
+}
+
+// computeType computes the type of n from the types of its children.
+// Reference: https://github.com/bitcoin/bitcoin/blob/v27.0/src/script/miniscript.cpp (ComputeType)
+func computeType(n *Node) Type {
+	var x, y, z Type
+	if len(n.subs) > 0 {
+		x = n.subs[0].typ
+	}
+	if len(n.subs) > 1 {
+		y = n.subs[1].typ
+	}
+	if len(n.subs) > 2 {
+		z = n.subs[2].typ
+	}
+	timelocks := mst("ghij")
+	switch n.fragment {
+	case fragment0:
+		return mst("Bzudemsxk")
+	case fragment1:
+		return mst("Bzufmxk")
+	case fragmentPkK:
+		return mst("Konudemsxk")
+	case fragmentPkH:
+		return mst("Knudemsxk")
+	case fragmentOlder:
+		return PropG.when(n.k&sequenceLocktimeTypeFlag != 0) | PropH.when(n.k&sequenceLocktimeTypeFlag == 0) | mst("Bzfmxk")
+	case fragmentAfter:
+		return PropI.when(n.k >= locktimeThreshold) | PropJ.when(n.k < locktimeThreshold) | mst("Bzfmxk")
+	case fragmentSha256, fragmentHash256, fragmentRipemd160, fragmentHash160:
+		return mst("Bonudmk")
+	case fragmentMulti:
+		return mst("Bnudemsk")
+	case fragmentMultiA:
+		return mst("Budemsk")
+	case fragmentWrapA:
+		return TypeW.when(x.Has(TypeB)) | x&(timelocks|PropK) | x&mst("udfems") | PropX
+	case fragmentWrapS:
+		return TypeW.when(x.Has(TypeB|PropO)) | x&(timelocks|PropK) | x&mst("udfemsx")
+	case fragmentWrapC:
+		return TypeB.when(x.Has(TypeK)) | x&(timelocks|PropK) | x&mst("ondfem") | PropU | PropS
+	case fragmentWrapD:
+		return TypeB.when(x.Has(TypeV|PropZ)) | PropO.when(x.Has(PropZ)) | PropE.when(x.Has(PropF)) |
+			x&(timelocks|PropK) | x&(PropM|PropS) | PropU.when(n.ctx == ContextTapscript) | PropN | PropD | PropX
+	case fragmentWrapV:
+		return TypeV.when(x.Has(TypeB)) | x&(timelocks|PropK) | x&mst("zonms") | PropF | PropX
+	case fragmentWrapJ:
+		return TypeB.when(x.Has(TypeB|PropN)) | PropE.when(x.Has(PropF)) | x&(timelocks|PropK) | x&mst("oums") | PropN | PropD | PropX
+	case fragmentWrapN:
+		return x&(timelocks|PropK) | x&mst("Bzondfems") | PropU | PropX
+	case fragmentAndV:
+		return (y & (TypeK | TypeV | TypeB)).when(x.Has(TypeV)) |
+			x&PropN | (y & PropN).when(x.Has(PropZ)) |
+			((x | y) & PropO).when((x | y).Has(PropZ)) |
+			x&y&(PropD|PropM|PropZ) |
+			(x|y)&PropS |
+			PropF.when(y.Has(PropF) || x.Has(PropS)) |
+			y&(PropU|PropX) |
+			(x|y)&timelocks |
+			PropK.when((x&y).Has(PropK) && !timelockMix(x, y))
+	case fragmentAndB:
+		return (x & TypeB).when(y.Has(TypeW)) |
+			((x | y) & PropO).when((x | y).Has(PropZ)) |
+			x&PropN | (y & PropN).when(x.Has(PropZ)) |
+			(x & y & PropE).when((x & y).Has(PropS)) |
+			x&y&(PropD|PropZ|PropM) |
+			PropF.when((x&y).Has(PropF) || x.Has(PropS|PropF) || y.Has(PropS|PropF)) |
+			(x|y)&PropS |
+			PropU | PropX |
+			(x|y)&timelocks |
+			PropK.when((x&y).Has(PropK) && !timelockMix(x, y))
+	case fragmentOrB:
+		return TypeB.when(x.Has(TypeB|PropD) && y.Has(TypeW|PropD)) |
+			((x | y) & PropO).when((x | y).Has(PropZ)) |
+			(x & y & PropM).when((x|y).Has(PropS) && (x&y).Has(PropE)) |
+			x&y&(PropZ|PropS|PropE) |
+			PropD | PropU | PropX |
+			(x|y)&timelocks |
+			x&y&PropK
+	case fragmentOrD:
+		return (y & TypeB).when(x.Has(TypeB|PropD|PropU)) |
+			(x & PropO).when(y.Has(PropZ)) |
+			(x & y & PropM).when(x.Has(PropE) && (x|y).Has(PropS)) |
+			x&y&(PropZ|PropE|PropS) |
+			y&(PropU|PropF|PropD) |
+			PropX |
+			(x|y)&timelocks |
+			x&y&PropK
+	case fragmentOrC:
+		return (y & TypeV).when(x.Has(TypeB|PropD|PropU)) |
+			(x & PropO).when(y.Has(PropZ)) |
+			(x & y & PropM).when(x.Has(PropE) && (x|y).Has(PropS)) |
+			x&y&(PropZ|PropS) |
+			PropF | PropX |
+			(x|y)&timelocks |
+			x&y&PropK
+	case fragmentOrI:
+		return x&y&(TypeV|TypeB|TypeK|PropU|PropF|PropS) |
+			PropO.when((x & y).Has(PropZ)) |
+			((x | y) & PropE).when((x | y).Has(PropF)) |
+			(x & y & PropM).when((x | y).Has(PropS)) |
+			(x|y)&PropD |
+			PropX |
+			(x|y)&timelocks |
+			x&y&PropK
+	case fragmentAndOr:
+		return (y & z & (TypeB | TypeK | TypeV)).when(x.Has(TypeB|PropD|PropU)) |
+			x&y&z&PropZ |
+			((x | (y & z)) & PropO).when((x | (y & z)).Has(PropZ)) |
+			y&z&PropU |
+			(z & PropF).when(x.Has(PropS) || y.Has(PropF)) |
+			z&PropD |
+			(z & PropE).when(x.Has(PropS) || y.Has(PropF)) |
+			(x & y & z & PropM).when(x.Has(PropE) && (x|y|z).Has(PropS)) |
+			z&(x|y)&PropS |
+			PropX |
+			(x|y|z)&timelocks |
+			PropK.when((x&y&z).Has(PropK) && !timelockMix(x, y))
+	case fragmentThresh:
+		allE := true
+		allM := true
+		args := 0
+		numS := 0
+		acc := PropK
+		for i, sub := range n.subs {
+			t := sub.typ
+			required := TypeW | PropD | PropU
+			if i == 0 {
+				required = TypeB | PropD | PropU
+			}
+			if !t.Has(required) {
+				return 0
+			}
+			if !t.Has(PropE) {
+				allE = false
+			}
+			if !t.Has(PropM) {
+				allM = false
+			}
+			if t.Has(PropS) {
+				numS++
+			}
+			switch {
+			case t.Has(PropZ):
+			case t.Has(PropO):
+				args++
+			default:
+				args += 2
+			}
+			acc = (acc|t)&timelocks |
+				PropK.when((acc&t).Has(PropK) && (n.k <= 1 || !timelockMix(acc, t)))
+		}
+		subs := len(n.subs)
+		return TypeB | PropD | PropU |
+			PropZ.when(args == 0) |
+			PropO.when(args == 1) |
+			PropE.when(allE && numS == subs) |
+			PropM.when(allE && allM && numS >= subs-int(n.k)) |
+			PropS.when(numS >= subs-int(n.k)+1) |
+			acc
+	}
+	return 0
+}
@@ -3,8 +3,9 @@ package bip32
 import (
 	"crypto/subtle"
 	"encoding/binary"
+	"errors"
+	"sync"
 
-	"github.com/koba-e964/base58-go"
 	"github.com/koba-e964/bip32-typesafe/secp256k1"
 )
 
@@ -15,7 +16,39 @@ type PrivateKey struct {
 	parentFingerprint [4]byte
 	childNumber       [4]byte
 	chainCode         [32]byte
-	privateKey        secp256k1.Scalar
+	privateKey        secp256k1.SecretScalar
+	origin            *KeyOrigin // nil if unknown; always nil for master keys, whose origin is computed on demand
+	public            *publicPart
+}
+
+// publicPart memoises the public key of a PrivateKey, which costs a scalar multiplication.
+// Copies of a PrivateKey with the same private key share it.
+type publicPart struct {
+	once        sync.Once
+	publicKey   secp256k1.Compressed
+	fingerprint [4]byte
+}
+
+// publicKey returns the compressed public key of this PrivateKey, computing it at most once.
+// It is safe for concurrent use.
+func (p *PrivateKey) publicKey() secp256k1.Compressed {
+	public := p.publicPart()
+	return public.publicKey
+}
+
+func (p *PrivateKey) publicPart() *publicPart {
+	public := p.public
+	if public == nil {
+		// not constructed in this package; compute without memoisation
+		public = &publicPart{}
+	}
+	public.once.Do(func() {
+		var pubKey secp256k1.Point
+		pubKey.GESecretPoint(p.privateKey)
+		public.publicKey = pubKey.Compress()
+		public.fingerprint = fingerprint(public.publicKey)
+	})
+	return public
 }
 
 // Depth returns the depth of this PrivateKey. If the depth is 0, this key is a master key.
@@ -39,25 +72,84 @@ func (p *PrivateKey) ChainCode() [32]byte {
 }
 
 // PrivateKey returns the private key of secp256k1 in this PrivateKey.
+// The result is declassified: it is a plain Scalar, which can be passed to variable-time functions and compared with ==.
+// Use SecretPrivateKey to keep the key in constant-time operations.
 func (p *PrivateKey) PrivateKey() secp256k1.Scalar {
+	return p.privateKey.Declassify()
+}
+
+// SecretPrivateKey returns the private key of secp256k1 in this PrivateKey as a SecretScalar,
+// which allows only constant-time operations.
+func (p *PrivateKey) SecretPrivateKey() secp256k1.SecretScalar {
 	return p.privateKey
 }
 
+// Fingerprint returns the fingerprint of this PrivateKey, namely the first 4 bytes of HASH160 of the public key.
+// Child keys of this PrivateKey have this value as their parent fingerprint.
+func (p *PrivateKey) Fingerprint() [4]byte {
+	return p.publicPart().fingerprint
+}
+
+// KeyOrigin returns the origin (the master key fingerprint and the derivation path) of this PrivateKey.
+// It returns nil if the origin is unknown, which is the case for non-master keys that were deserialized and not given an origin with WithKeyOrigin.
+// Keys derived from a key with a known origin have their origin tracked automatically.
+func (p *PrivateKey) KeyOrigin() *KeyOrigin {
+	if p.depth == 0 {
+		return &KeyOrigin{MasterFingerprint: p.Fingerprint()}
+	}
+	return p.origin.clone()
+}
+
+// WithKeyOrigin returns a copy of this PrivateKey with the given origin.
+// ErrorKeyOriginMismatch is returned if the length of the path is not the depth of this PrivateKey,
+// the last index of the path is not the child number of this PrivateKey,
+// or this PrivateKey is a master key and origin.MasterFingerprint is not its fingerprint.
+func (p *PrivateKey) WithKeyOrigin(origin KeyOrigin) (*PrivateKey, error) {
+	var masterFingerprint [4]byte
+	if p.depth == 0 {
+		masterFingerprint = p.Fingerprint()
+	}
+	if err := checkKeyOrigin(origin, p.depth, p.childNumber, masterFingerprint); err != nil {
+		return nil, err
+	}
+	result := *p
+	result.origin = nil
+	if p.depth != 0 {
+		result.origin = origin.clone()
+	}
+	return &result, nil
+}
+
+// IsTestnet returns true if this PrivateKey is for testnet (tprv), false if it is for mainnet (xprv).
+func (p *PrivateKey) IsTestnet() bool {
+	return p.version == [4]byte(testnetPrivateKeyVersion)
+}
+
+// WithNetwork returns a copy of this PrivateKey for testnet (tprv) if testnet is true, for mainnet (xprv) otherwise.
+func (p *PrivateKey) WithNetwork(testnet bool) *PrivateKey {
+	result := *p
+	result.version = [4]byte(privateKeyVersion)
+	if testnet {
+		result.version = [4]byte(testnetPrivateKeyVersion)
+	}
+	result.origin = p.origin.clone()
+	return &result
+}
+
 // GetPublicKey finds the corresponding PublicKey from this PrivateKey.
 func (p *PrivateKey) GetPublicKey() *PublicKey {
 	version := publicKeyVersion
 	if p.version == [4]byte(testnetPrivateKeyVersion) {
 		version = testnetPublicKeyVersion
 	}
-	var pubKey secp256k1.Point
-	pubKey.GEPoint(p.privateKey)
 	publicKey := PublicKey{
 		version:           [4]byte(version),
 		depth:             p.depth,
 		parentFingerprint: p.parentFingerprint,
 		childNumber:       p.childNumber,
 		chainCode:         p.chainCode,
-		publicKey:         pubKey.Compress(),
+		publicKey:         p.publicKey(),
+		origin:            p.origin.clone(),
 	}
 	return &publicKey
 }
@@ -78,7 +170,8 @@ func (p *PrivateKey) Serialize() [KeyLengthInBytes]byte {
 
 	// result[45] = 0 is implicitly achieved
 
-	copy(result[46:78], p.privateKey[:])
+	privateKey := p.privateKey.Declassify()
+	copy(result[46:78], privateKey[:])
 
 	chksum := checksum(result[:78])
 	copy(result[78:], chksum[:])
@@ -92,28 +185,31 @@ func (p *PrivateKey) B58Serialize() string {
 }
 
 // B58DeserializePrivateKey decodes a base58-encoded string and
-// returns a PrivateKey.
+// returns a PrivateKey. The returned error is a *DecodeError.
+// A string with a character outside the base58 alphabet is rejected with ErrorInvalidEncoding.
 func B58DeserializePrivateKey(encoded string) (*PrivateKey, error) {
 	if len(encoded) != 111 {
-		return nil, ErrorInvalidKeyLength
+		return nil, newDecodeError(fieldLength, ErrorInvalidKeyLength)
+	}
+	var data [KeyLengthInBytes]byte
+	if base58DecodeCanonical(encoded, data[:]) != 1 {
+		return nil, newDecodeError(fieldEncoding, ErrorInvalidEncoding)
 	}
-	var data [82]byte
-	base58.Decode(encoded, data[:])
 	return DeserializePrivateKey(data)
 }
 
 // DeserializePrivateKey reads a []byte and
-// returns a PrivateKey.
+// returns a PrivateKey. The returned error is a *DecodeError.
 func DeserializePrivateKey(data [KeyLengthInBytes]byte) (*PrivateKey, error) {
-	p := PrivateKey{}
+	p := PrivateKey{public: &publicPart{}}
 
 	chksum := checksum(data[:78])
 	if subtle.ConstantTimeCompare(data[78:], chksum[:]) != 1 {
-		return nil, ErrorChecksumMismatch
+		return nil, newDecodeError(fieldChecksum, ErrorChecksumMismatch)
 	}
 
 	if (subtle.ConstantTimeCompare(data[:4], privateKeyVersion) | subtle.ConstantTimeCompare(data[:4], testnetPrivateKeyVersion)) != 1 {
-		return nil, ErrorInvalidVersion
+		return nil, newDecodeError(fieldVersion, ErrorInvalidVersion)
 	}
 	p.version = [4]byte(data[:4])
 
@@ -122,60 +218,101 @@ func DeserializePrivateKey(data [KeyLengthInBytes]byte) (*PrivateKey, error) {
 	copy(p.parentFingerprint[:], data[5:9])
 
 	if (subtle.ConstantTimeByteEq(p.depth, 0) & (subtle.ConstantTimeCompare(p.parentFingerprint[:], make([]byte, 4)) ^ 1)) == 1 {
-		return nil, ErrorZeroDepthAndNonZeroParentFingerprint
+		return nil, newDecodeError(fieldParentFingerprint, ErrorZeroDepthAndNonZeroParentFingerprint)
 	}
 
 	copy(p.childNumber[:], data[9:13])
 
 	if (subtle.ConstantTimeByteEq(p.depth, 0) & (subtle.ConstantTimeCompare(p.childNumber[:], make([]byte, 4)) ^ 1)) == 1 {
-		return nil, ErrorZeroDepthAndNonZeroIndex
+		return nil, newDecodeError(fieldChildNumber, ErrorZeroDepthAndNonZeroIndex)
 	}
 
 	copy(p.chainCode[:], data[13:45])
 
 	if data[45] != 0 {
-		return nil, ErrorInvalidPrivateKey
+		return nil, newDecodeError(fieldKeyData, ErrorInvalidPrivateKey)
 	}
-	copy(p.privateKey[:], data[46:78])
+	p.privateKey = secp256k1.NewSecretScalar(secp256k1.Scalar(data[46:78]))
 
-	// 0 < privateKey < secp256k1.Order
-	inRange := subtle.ConstantTimeEq(int32(secp256k1.CompareBytes([32]byte{}, p.privateKey)), -1) &
-		subtle.ConstantTimeEq(int32(secp256k1.CompareBytes(p.privateKey, secp256k1.Order)), -1)
-	if inRange != 1 {
-		return nil, ErrorPrivateKeyNotInRange
+	if isPrivateKeyInRange(p.privateKey) != 1 {
+		return nil, newDecodeError(fieldKeyData, ErrorPrivateKeyNotInRange)
 	}
 
 	return &p, nil
 }
 
-// NewChildKey derives a new child key from this PrivateKey. The following errors may be returned:
+// isPrivateKeyInRange returns 1 if 0 < privateKey < secp256k1.Order, 0 otherwise. It runs in constant-time.
+func isPrivateKeyInRange(privateKey secp256k1.SecretScalar) int {
+	return privateKey.IsValid() &^ privateKey.IsZero()
+}
+
+// NewChildKey derives a new child key from this PrivateKey.
+// The returned error is a *DerivationError, whose Reason is one of the following errors:
 //   - ErrorTooDeepKey: if this PrivateKey has depth 255
 //   - ErrorInvalidPrivateKey: if the derived private key satisfies parse_{256}(I_L) >= n or k_i = 0 (with probability < 2^{-127})
 func (p *PrivateKey) NewChildKey(childIdx uint32) (*PrivateKey, error) {
+	return p.newChildKey(childIdx, childHMAC)
+}
+
+// newChildKey is NewChildKey with I_L and I_R computed by hmacFunc.
+func (p *PrivateKey) newChildKey(childIdx uint32, hmacFunc childHMACFunc) (*PrivateKey, error) {
 	if p.depth == 255 {
-		return nil, ErrorTooDeepKey
+		return nil, &DerivationError{Depth: p.depth, Index: childIdx, Reason: ErrorTooDeepKey}
 	}
-	var pubPart secp256k1.Point
-	pubPart.GEPoint(p.privateKey)
-	pubPartCompressed := pubPart.Compress()
-	keyData := [33]byte(append([]byte{0x00}, p.privateKey[:]...))
+	// the public key is needed for the fingerprint even for hardened children, so it is memoised
+	public := p.publicPart()
+	privateKey := p.privateKey.Declassify()
+	keyData := [33]byte(append([]byte{0x00}, privateKey[:]...))
 	if childIdx < FirstHardenedChildIndex {
-		keyData = pubPartCompressed
+		keyData = public.publicKey
 	}
-	l := hmacThing(p.chainCode, keyData, childIdx)
-	ll := [32]byte(l[:32])
-	lr := [32]byte(l[32:])
+	ll, lr := hmacFunc(p.chainCode, keyData, childIdx)
 	child := PrivateKey{
 		version:           p.version,
 		depth:             p.depth + 1,
-		parentFingerprint: [4]byte(hash160(pubPartCompressed[:])[:4]),
+		parentFingerprint: public.fingerprint,
 		childNumber:       uint32ToBytes(childIdx),
 		chainCode:         lr,
-		privateKey:        secp256k1.SCAdd(ll, p.privateKey),
+		privateKey:        secp256k1.NewSecretScalar(ll).Add(p.privateKey),
+		public:            &publicPart{},
+	}
+	origin := p.origin
+	if p.depth == 0 {
+		origin = &KeyOrigin{MasterFingerprint: child.parentFingerprint}
 	}
-	cmp := secp256k1.SCIsValid(ll) & (subtle.ConstantTimeCompare(child.privateKey[:], make([]byte, 32)) ^ 1)
+	child.origin = origin.child(childIdx)
+	cmp := secp256k1.NewSecretScalar(ll).IsValid() &^ child.privateKey.IsZero()
 	if cmp != 1 {
-		return nil, ErrorInvalidPrivateKey
+		return nil, &DerivationError{Depth: p.depth, Index: childIdx, Reason: ErrorInvalidPrivateKey}
 	}
 	return &child, nil
 }
+
+// NewChildKeySkipInvalid derives a new child key from this PrivateKey.
+// Unlike NewChildKey, if the child key at childIdx is invalid, it proceeds with the next index as BIP 32 specifies.
+// It returns the derived key together with the index actually used.
+// The index never crosses the boundary between non-hardened and hardened indices.
+// The following errors may be returned:
+//   - ErrorTooDeepKey: if this PrivateKey has depth 255
+//   - ErrorInvalidPrivateKey: if there is no valid child key in the rest of the index range (practically impossible)
+func (p *PrivateKey) NewChildKeySkipInvalid(childIdx uint32) (*PrivateKey, uint32, error) {
+	return p.newChildKeySkipInvalid(childIdx, childHMAC)
+}
+
+// newChildKeySkipInvalid is NewChildKeySkipInvalid with I_L and I_R computed by hmacFunc.
+func (p *PrivateKey) newChildKeySkipInvalid(childIdx uint32, hmacFunc childHMACFunc) (*PrivateKey, uint32, error) {
+	for {
+		child, err := p.newChildKey(childIdx, hmacFunc)
+		if !errors.Is(err, ErrorInvalidPrivateKey) {
+			if err != nil {
+				return nil, 0, err
+			}
+			return child, childIdx, nil
+		}
+		next, ok := nextChildIndex(childIdx)
+		if !ok {
+			return nil, 0, err
+		}
+		childIdx = next
+	}
+}
@@ -0,0 +1,320 @@
+package psbt
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+
+	bip32 "github.com/koba-e964/bip32-typesafe"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+	//lint:ignore SA1019 PSBTs have RIPEMD-160 preimages and HASH160 is used in scripts, so using RIPEMD-160 is inevitable.
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Derivation is a PSBT_IN_BIP32_DERIVATION or PSBT_OUT_BIP32_DERIVATION entry:
+// a public key and the origin of its private key.
+type Derivation struct {
+	PublicKey secp256k1.Compressed
+	Origin    bip32.KeyOrigin
+}
+
+// TapDerivation is a PSBT_IN_TAP_BIP32_DERIVATION or PSBT_OUT_TAP_BIP32_DERIVATION entry:
+// an x-only public key (BIP 340), the tapleaf hashes of the scripts it is used in, and the origin of its private key.
+type TapDerivation struct {
+	XOnlyPublicKey [32]byte
+	LeafHashes     [][32]byte
+	Origin         bip32.KeyOrigin
+}
+
+// Xpub is a PSBT_GLOBAL_XPUB entry.
+type Xpub struct {
+	PublicKey *bip32.PublicKey
+	Origin    bip32.KeyOrigin
+}
+
+// Derive derives the private key of d from key.
+// key matches d if the origin of key is a prefix of d.Origin, or the fingerprint of key is d.Origin.MasterFingerprint.
+// ErrorNoMatchingKey is returned if key doesn't match d,
+// and ErrorKeyMismatch is returned if key matches d but the derived public key is not d.PublicKey.
+func (d *Derivation) Derive(key *bip32.PrivateKey) (*bip32.PrivateKey, error) {
+	child, err := derive(key, d.Origin)
+	if err != nil {
+		return nil, err
+	}
+	if child.GetPublicKey().PublicKey() != d.PublicKey {
+		return nil, ErrorKeyMismatch
+	}
+	return child, nil
+}
+
+// Derive derives the private key of d from key. It matches key against d in the same way as Derivation.Derive.
+func (d *TapDerivation) Derive(key *bip32.PrivateKey) (*bip32.PrivateKey, error) {
+	child, err := derive(key, d.Origin)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := child.GetPublicKey().PublicKey()
+	if [32]byte(publicKey[1:]) != d.XOnlyPublicKey {
+		return nil, ErrorKeyMismatch
+	}
+	return child, nil
+}
+
+func derive(key *bip32.PrivateKey, origin bip32.KeyOrigin) (*bip32.PrivateKey, error) {
+	var path []uint32
+	keyOrigin := key.KeyOrigin()
+	switch {
+	case keyOrigin != nil && keyOrigin.MasterFingerprint == origin.MasterFingerprint &&
+		len(keyOrigin.Path) <= len(origin.Path) && pathEqual(keyOrigin.Path, origin.Path[:len(keyOrigin.Path)]):
+		path = origin.Path[len(keyOrigin.Path):]
+	case key.Fingerprint() == origin.MasterFingerprint:
+		// key is used as a master key
+		path = origin.Path
+	default:
+		return nil, ErrorNoMatchingKey
+	}
+	for _, childIdx := range path {
+		child, err := key.NewChildKey(childIdx)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+	return key, nil
+}
+
+func pathEqual(a []uint32, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseKeyOrigin parses a fingerprint followed by little-endian 32-bit child indices.
+func parseKeyOrigin(value []byte) (bip32.KeyOrigin, error) {
+	if len(value) < 4 || len(value)%4 != 0 {
+		return bip32.KeyOrigin{}, ErrorInvalidValue
+	}
+	origin := bip32.KeyOrigin{MasterFingerprint: [4]byte(value[:4])}
+	for i := 4; i < len(value); i += 4 {
+		origin.Path = append(origin.Path, binary.LittleEndian.Uint32(value[i:]))
+	}
+	return origin, nil
+}
+
+func appendKeyOrigin(b []byte, origin bip32.KeyOrigin) []byte {
+	b = append(b, origin.MasterFingerprint[:]...)
+	for _, childIdx := range origin.Path {
+		b = appendUint32(b, childIdx)
+	}
+	return b
+}
+
+// parseTapDerivationValue parses the leaf hashes and the key origin of a tap BIP 32 derivation entry.
+func parseTapDerivationValue(value []byte) ([][32]byte, bip32.KeyOrigin, error) {
+	r := reader{data: value}
+	n := r.readCompactSize()
+	if r.err != nil || n > uint64(len(r.data))/32 {
+		return nil, bip32.KeyOrigin{}, ErrorInvalidValue
+	}
+	leafHashes := make([][32]byte, n)
+	for i := range leafHashes {
+		leafHashes[i] = [32]byte(r.read(32))
+	}
+	origin, err := parseKeyOrigin(r.data)
+	if err != nil {
+		return nil, bip32.KeyOrigin{}, err
+	}
+	return leafHashes, origin, nil
+}
+
+func parseXpub(data []byte) (*bip32.PublicKey, error) {
+	if len(data) != bip32.KeyLengthInBytes-4 {
+		return nil, ErrorInvalidKey
+	}
+	// the serialization of an extended key in PSBT_GLOBAL_XPUB doesn't have the checksum
+	var serialized [bip32.KeyLengthInBytes]byte
+	copy(serialized[:], data)
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	copy(serialized[len(data):], second[:4])
+	return bip32.DeserializePublicKey(serialized)
+}
+
+// checkPublicKey checks that data is a compressed or uncompressed public key on secp256k1.
+func checkPublicKey(data []byte) error {
+	if _, err := secp256k1.ParsePoint(data); err != nil {
+		return ErrorInvalidKey
+	}
+	return nil
+}
+
+// checkXOnlyPublicKey checks that data is an x-only public key (BIP 340) on secp256k1.
+func checkXOnlyPublicKey(data []byte) error {
+	if len(data) != 32 {
+		return ErrorInvalidKey
+	}
+	if _, err := secp256k1.Compressed(append([]byte{0x02}, data...)).Uncompress(); err != nil {
+		return ErrorInvalidKey
+	}
+	return nil
+}
+
+func hash160(data []byte) []byte {
+	hash := ripemd160.New()
+	intermediate := sha256.Sum256(data)
+	hash.Write(intermediate[:])
+	return hash.Sum(nil)
+}
+
+// preimageHash hashes a preimage with the hash function of the key type of a preimage field.
+func preimageHash(keyType byte, preimage []byte) []byte {
+	switch keyType {
+	case inRipemd160:
+		hash := ripemd160.New()
+		hash.Write(preimage)
+		return hash.Sum(nil)
+	case inSha256:
+		hash := sha256.Sum256(preimage)
+		return hash[:]
+	case inHash160:
+		return hash160(preimage)
+	}
+	hash := doubleSHA256(preimage)
+	return hash[:]
+}
+
+// NonWitnessUTXO returns the transaction spent by this input (PSBT_IN_NON_WITNESS_UTXO), or nil if it is absent.
+func (in *Input) NonWitnessUTXO() *Tx {
+	value, ok := in.get(inNonWitnessUTXO)
+	if !ok {
+		return nil
+	}
+	// validated in Deserialize
+	tx, _ := DeserializeTx(value)
+	return tx
+}
+
+// WitnessUTXO returns the output spent by this input (PSBT_IN_WITNESS_UTXO), or nil if it is absent.
+func (in *Input) WitnessUTXO() *TxOut {
+	value, ok := in.get(inWitnessUTXO)
+	if !ok {
+		return nil
+	}
+	out, _ := deserializeTxOut(value)
+	return out
+}
+
+// SighashType returns the signature hash type of this input (PSBT_IN_SIGHASH_TYPE). It returns SighashAll if it is absent.
+// ErrorInvalidValue is returned if the value is not 4 bytes long, which is possible if it was changed with Set.
+func (in *Input) SighashType() (uint32, error) {
+	value, ok := in.get(inSighashType)
+	if !ok {
+		return SighashAll, nil
+	}
+	if len(value) != 4 {
+		return 0, ErrorInvalidValue
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
+
+// RedeemScript returns the redeem script of this input, or nil if it is absent.
+func (in *Input) RedeemScript() []byte {
+	value, _ := in.get(inRedeemScript)
+	return value
+}
+
+// WitnessScript returns the witness script of this input, or nil if it is absent.
+func (in *Input) WitnessScript() []byte {
+	value, _ := in.get(inWitnessScript)
+	return value
+}
+
+// PartialSignatures returns the partial signatures of this input. Each value is a DER signature followed by the hash type.
+// Signatures by uncompressed public keys are not included.
+func (in *Input) PartialSignatures() map[secp256k1.Compressed][]byte {
+	result := map[secp256k1.Compressed][]byte{}
+	for _, pair := range in.getAll(inPartialSig) {
+		if len(pair.Key) == 34 {
+			result[secp256k1.Compressed(pair.Key[1:])] = pair.Value
+		}
+	}
+	return result
+}
+
+// AddPartialSignature adds a partial signature of publicKey to this input.
+// ErrorInvalidSighashType is returned if hashType doesn't fit in the byte appended to the signature.
+func (in *Input) AddPartialSignature(publicKey secp256k1.Compressed, signature *secp256k1.ECDSASignature, hashType uint32) error {
+	if hashType > 0xff {
+		return ErrorInvalidSighashType
+	}
+	in.Set(append([]byte{inPartialSig}, publicKey[:]...), append(signature.DER(), byte(hashType)))
+	return nil
+}
+
+// BIP32Derivations returns the PSBT_IN_BIP32_DERIVATION entries of this input. Entries of uncompressed public keys are skipped.
+func (in *Input) BIP32Derivations() []Derivation {
+	return derivations(&in.Map, inBIP32Derivation)
+}
+
+// TapBIP32Derivations returns the PSBT_IN_TAP_BIP32_DERIVATION entries of this input.
+func (in *Input) TapBIP32Derivations() []TapDerivation {
+	return tapDerivations(&in.Map, inTapBIP32Derivation)
+}
+
+// AddBIP32Derivation adds a PSBT_IN_BIP32_DERIVATION entry to this input.
+func (in *Input) AddBIP32Derivation(d Derivation) {
+	in.Set(append([]byte{inBIP32Derivation}, d.PublicKey[:]...), appendKeyOrigin(nil, d.Origin))
+}
+
+// BIP32Derivations returns the PSBT_OUT_BIP32_DERIVATION entries of this output. Entries of uncompressed public keys are skipped.
+func (out *Output) BIP32Derivations() []Derivation {
+	return derivations(&out.Map, outBIP32Derivation)
+}
+
+// TapBIP32Derivations returns the PSBT_OUT_TAP_BIP32_DERIVATION entries of this output.
+func (out *Output) TapBIP32Derivations() []TapDerivation {
+	return tapDerivations(&out.Map, outTapBIP32Derivation)
+}
+
+// AddBIP32Derivation adds a PSBT_OUT_BIP32_DERIVATION entry to this output.
+func (out *Output) AddBIP32Derivation(d Derivation) {
+	out.Set(append([]byte{outBIP32Derivation}, d.PublicKey[:]...), appendKeyOrigin(nil, d.Origin))
+}
+
+// Xpubs returns the PSBT_GLOBAL_XPUB entries.
+func (p *Packet) Xpubs() []Xpub {
+	var result []Xpub
+	for _, pair := range p.Global.getAll(globalXpub) {
+		// validated in Deserialize
+		publicKey, _ := parseXpub(pair.Key[1:])
+		origin, _ := parseKeyOrigin(pair.Value)
+		result = append(result, Xpub{PublicKey: publicKey, Origin: origin})
+	}
+	return result
+}
+
+func derivations(m *Map, keyType byte) []Derivation {
+	var result []Derivation
+	for _, pair := range m.getAll(keyType) {
+		if len(pair.Key) != 34 {
+			continue
+		}
+		origin, _ := parseKeyOrigin(pair.Value)
+		result = append(result, Derivation{PublicKey: secp256k1.Compressed(pair.Key[1:]), Origin: origin})
+	}
+	return result
+}
+
+func tapDerivations(m *Map, keyType byte) []TapDerivation {
+	var result []TapDerivation
+	for _, pair := range m.getAll(keyType) {
+		leafHashes, origin, _ := parseTapDerivationValue(pair.Value)
+		result = append(result, TapDerivation{XOnlyPublicKey: [32]byte(pair.Key[1:]), LeafHashes: leafHashes, Origin: origin})
+	}
+	return result
+}
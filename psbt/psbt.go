@@ -0,0 +1,686 @@
+// Package psbt implements partially signed Bitcoin transactions (PSBT) version 0 (BIP 174) and version 2 (BIP 370),
+// with BIP 32 derivation paths and ECDSA signing by keys derived from a bip32.PrivateKey.
+//
+// Spec: https://github.com/bitcoin/bips/blob/master/bip-0174.mediawiki
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+)
+
+var (
+	ErrorInvalidMagic            = errors.New("PSBT magic bytes are invalid")
+	ErrorUnexpectedEOF           = errors.New("unexpected end of data")
+	ErrorNonCanonicalCompactSize = errors.New("CompactSize is not minimally encoded")
+	ErrorInvalidTransaction      = errors.New("transaction is invalid")
+	ErrorDuplicateKey            = errors.New("duplicate key in PSBT map")
+	ErrorInvalidKey              = errors.New("key in PSBT map is invalid")
+	ErrorInvalidValue            = errors.New("value in PSBT map is invalid")
+	ErrorMissingField            = errors.New("required field is missing")
+	ErrorUnexpectedField         = errors.New("field is not allowed in this PSBT version")
+	ErrorUnsupportedVersion      = errors.New("PSBT version is not supported")
+	ErrorTrailingData            = errors.New("trailing data after PSBT")
+	ErrorUTXOMismatch            = errors.New("non-witness UTXO doesn't match the outpoint")
+	ErrorLocktimeConflict        = errors.New("inputs require incompatible locktimes")
+	ErrorMissingUTXO             = errors.New("UTXO of the input is missing")
+	ErrorScriptMismatch          = errors.New("redeem script or witness script doesn't match the UTXO")
+	ErrorUnsupportedScript       = errors.New("script type is not supported for signing")
+	ErrorNoMatchingKey           = errors.New("key doesn't match the key origin")
+	ErrorKeyMismatch             = errors.New("derived public key doesn't match")
+	ErrorInvalidSighashType      = errors.New("sighash type must be < 256 for ECDSA signatures")
+)
+
+var magic = []byte{'p', 's', 'b', 't', 0xff}
+
+// key types (BIP 174 and BIP 370)
+const (
+	globalUnsignedTx       = 0x00
+	globalXpub             = 0x01
+	globalTxVersion        = 0x02
+	globalFallbackLocktime = 0x03
+	globalInputCount       = 0x04
+	globalOutputCount      = 0x05
+	globalTxModifiable     = 0x06
+	globalVersion          = 0xfb
+
+	inNonWitnessUTXO         = 0x00
+	inWitnessUTXO            = 0x01
+	inPartialSig             = 0x02
+	inSighashType            = 0x03
+	inRedeemScript           = 0x04
+	inWitnessScript          = 0x05
+	inBIP32Derivation        = 0x06
+	inFinalScriptSig         = 0x07
+	inFinalScriptWitness     = 0x08
+	inPORCommitment          = 0x09
+	inRipemd160              = 0x0a
+	inSha256                 = 0x0b
+	inHash160                = 0x0c
+	inHash256                = 0x0d
+	inPreviousTxID           = 0x0e
+	inOutputIndex            = 0x0f
+	inSequence               = 0x10
+	inRequiredTimeLocktime   = 0x11
+	inRequiredHeightLocktime = 0x12
+	inTapKeySig              = 0x13
+	inTapScriptSig           = 0x14
+	inTapLeafScript          = 0x15
+	inTapBIP32Derivation     = 0x16
+	inTapInternalKey         = 0x17
+	inTapMerkleRoot          = 0x18
+
+	outRedeemScript       = 0x00
+	outWitnessScript      = 0x01
+	outBIP32Derivation    = 0x02
+	outAmount             = 0x03
+	outScript             = 0x04
+	outTapInternalKey     = 0x05
+	outTapTree            = 0x06
+	outTapBIP32Derivation = 0x07
+)
+
+// locktimes below this value are block heights (BIP 65)
+const locktimeThreshold = 500000000
+
+// Pair is a key-value pair in a PSBT map. Key starts with the key type.
+type Pair struct {
+	Key   []byte
+	Value []byte
+}
+
+// Map is a PSBT map. The order of pairs is preserved, so that serialization reproduces the parsed bytes.
+type Map struct {
+	pairs []Pair
+}
+
+// Pairs returns all pairs in m, including unknown and proprietary ones.
+func (m *Map) Pairs() []Pair {
+	return append([]Pair(nil), m.pairs...)
+}
+
+// Get returns the value for key.
+func (m *Map) Get(key []byte) ([]byte, bool) {
+	for _, pair := range m.pairs {
+		if bytes.Equal(pair.Key, key) {
+			return pair.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Set sets the value for key. A new pair is inserted before the first pair with a greater key,
+// which keeps maps in the canonical order of Bitcoin Core.
+// Set doesn't validate pairs; use Serialize and Deserialize to check the result.
+func (m *Map) Set(key []byte, value []byte) {
+	for i, pair := range m.pairs {
+		switch bytes.Compare(pair.Key, key) {
+		case 0:
+			m.pairs[i].Value = value
+			return
+		case 1:
+			m.pairs = append(m.pairs[:i], append([]Pair{{Key: key, Value: value}}, m.pairs[i:]...)...)
+			return
+		}
+	}
+	m.pairs = append(m.pairs, Pair{Key: key, Value: value})
+}
+
+// get returns the value of the pair with a 1-byte key keyType.
+func (m *Map) get(keyType byte) ([]byte, bool) {
+	return m.Get([]byte{keyType})
+}
+
+// getAll returns all pairs of keyType.
+func (m *Map) getAll(keyType byte) []Pair {
+	var result []Pair
+	for _, pair := range m.pairs {
+		if pair.Key[0] == keyType {
+			result = append(result, pair)
+		}
+	}
+	return result
+}
+
+// Packet is a partially signed Bitcoin transaction.
+type Packet struct {
+	Global  Map
+	Inputs  []*Input
+	Outputs []*Output
+}
+
+// Input is a PSBT input map.
+type Input struct {
+	Map
+}
+
+// Output is a PSBT output map.
+type Output struct {
+	Map
+}
+
+// B64Deserialize parses a base64-encoded PSBT.
+func B64Deserialize(encoded string) (*Packet, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return Deserialize(data)
+}
+
+// Deserialize parses and validates a PSBT of version 0 or 2.
+func Deserialize(data []byte) (*Packet, error) {
+	if !bytes.HasPrefix(data, magic) {
+		return nil, ErrorInvalidMagic
+	}
+	r := reader{data: data[len(magic):]}
+	p := Packet{}
+	global, err := readMap(&r)
+	if err != nil {
+		return nil, err
+	}
+	p.Global = *global
+	version, err := validateGlobal(&p.Global)
+	if err != nil {
+		return nil, err
+	}
+	var numInputs, numOutputs uint64
+	var unsignedTx *Tx
+	if version == 0 {
+		value, _ := p.Global.get(globalUnsignedTx)
+		// validated in validateGlobal
+		unsignedTx, _ = deserializeTx(value, false)
+		numInputs, numOutputs = uint64(len(unsignedTx.Inputs)), uint64(len(unsignedTx.Outputs))
+	} else {
+		value, _ := p.Global.get(globalInputCount)
+		numInputs = (&reader{data: value}).readCompactSize()
+		value, _ = p.Global.get(globalOutputCount)
+		numOutputs = (&reader{data: value}).readCompactSize()
+	}
+	for i := uint64(0); i < numInputs; i++ {
+		m, err := readMap(&r)
+		if err != nil {
+			return nil, err
+		}
+		input := &Input{Map: *m}
+		if err := input.validate(version); err != nil {
+			return nil, err
+		}
+		if err := input.checkUTXO(unsignedTx, int(i)); err != nil {
+			return nil, err
+		}
+		p.Inputs = append(p.Inputs, input)
+	}
+	for i := uint64(0); i < numOutputs; i++ {
+		m, err := readMap(&r)
+		if err != nil {
+			return nil, err
+		}
+		output := &Output{Map: *m}
+		if err := output.validate(version); err != nil {
+			return nil, err
+		}
+		p.Outputs = append(p.Outputs, output)
+	}
+	if len(r.data) != 0 {
+		return nil, ErrorTrailingData
+	}
+	return &p, nil
+}
+
+// readMap reads key-value pairs until the separator 0x00.
+func readMap(r *reader) (*Map, error) {
+	m := Map{}
+	seen := map[string]bool{}
+	for {
+		key := r.readVarBytes()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if len(key) == 0 {
+			return &m, nil
+		}
+		value := r.readVarBytes()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if seen[string(key)] {
+			return nil, ErrorDuplicateKey
+		}
+		seen[string(key)] = true
+		m.pairs = append(m.pairs, Pair{Key: key, Value: value})
+	}
+}
+
+// Serialize returns the serialization of p.
+func (p *Packet) Serialize() []byte {
+	b := append([]byte(nil), magic...)
+	b = p.Global.append(b)
+	for _, input := range p.Inputs {
+		b = input.append(b)
+	}
+	for _, output := range p.Outputs {
+		b = output.append(b)
+	}
+	return b
+}
+
+// B64Serialize returns the base64 encoding of the serialization of p.
+func (p *Packet) B64Serialize() string {
+	return base64.StdEncoding.EncodeToString(p.Serialize())
+}
+
+func (m *Map) append(b []byte) []byte {
+	for _, pair := range m.pairs {
+		b = appendVarBytes(b, pair.Key)
+		b = appendVarBytes(b, pair.Value)
+	}
+	return append(b, 0x00)
+}
+
+// Version returns the PSBT version, which is 0 or 2.
+func (p *Packet) Version() uint32 {
+	value, ok := p.Global.get(globalVersion)
+	if !ok {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(value)
+}
+
+// fieldVersions lists key types that are allowed only in one version.
+var (
+	globalFieldVersions = map[byte]uint32{
+		globalUnsignedTx:       0,
+		globalTxVersion:        2,
+		globalFallbackLocktime: 2,
+		globalInputCount:       2,
+		globalOutputCount:      2,
+		globalTxModifiable:     2,
+	}
+	inputFieldVersions = map[byte]uint32{
+		inPreviousTxID:           2,
+		inOutputIndex:            2,
+		inSequence:               2,
+		inRequiredTimeLocktime:   2,
+		inRequiredHeightLocktime: 2,
+	}
+	outputFieldVersions = map[byte]uint32{
+		outAmount: 2,
+		outScript: 2,
+	}
+)
+
+// checkFields checks that fields of the other version are absent and the required fields are present.
+// Pairs of key types of the other version with longer keys are unknown pairs, which are allowed.
+func checkFields(m *Map, version uint32, versions map[byte]uint32, required []byte) error {
+	for _, pair := range m.pairs {
+		if v, ok := versions[pair.Key[0]]; ok && v != version && len(pair.Key) == 1 {
+			return ErrorUnexpectedField
+		}
+	}
+	for _, keyType := range required {
+		if _, ok := m.get(keyType); !ok {
+			return ErrorMissingField
+		}
+	}
+	return nil
+}
+
+// validateGlobal validates the global map and returns the PSBT version.
+func validateGlobal(m *Map) (uint32, error) {
+	version := uint32(0)
+	if value, ok := m.get(globalVersion); ok {
+		if len(value) != 4 {
+			return 0, ErrorInvalidValue
+		}
+		version = binary.LittleEndian.Uint32(value)
+		if version != 0 && version != 2 {
+			return 0, ErrorUnsupportedVersion
+		}
+	}
+	valueLengths := map[byte]int{
+		globalTxVersion:        4,
+		globalFallbackLocktime: 4,
+		globalTxModifiable:     1,
+	}
+	for _, pair := range m.pairs {
+		keyType := pair.Key[0]
+		if v, ok := globalFieldVersions[keyType]; ok && v != version {
+			continue
+		}
+		switch keyType {
+		case globalXpub:
+			if len(pair.Key) != 79 {
+				return 0, ErrorInvalidKey
+			}
+			if _, err := parseXpub(pair.Key[1:]); err != nil {
+				return 0, ErrorInvalidKey
+			}
+			if _, err := parseKeyOrigin(pair.Value); err != nil {
+				return 0, err
+			}
+			continue
+		case globalUnsignedTx, globalTxVersion, globalFallbackLocktime, globalInputCount, globalOutputCount, globalTxModifiable, globalVersion:
+			if len(pair.Key) != 1 {
+				return 0, ErrorInvalidKey
+			}
+		default:
+			continue
+		}
+		if length, ok := valueLengths[keyType]; ok && len(pair.Value) != length {
+			return 0, ErrorInvalidValue
+		}
+		switch keyType {
+		case globalUnsignedTx:
+			tx, err := deserializeTx(pair.Value, false)
+			if err != nil {
+				return 0, err
+			}
+			for _, in := range tx.Inputs {
+				if len(in.ScriptSig) != 0 {
+					return 0, ErrorInvalidTransaction
+				}
+			}
+		case globalInputCount, globalOutputCount:
+			r := reader{data: pair.Value}
+			r.readCompactSize()
+			if r.err != nil || len(r.data) != 0 {
+				return 0, ErrorInvalidValue
+			}
+		}
+	}
+	required := []byte{globalUnsignedTx}
+	if version == 2 {
+		required = []byte{globalTxVersion, globalInputCount, globalOutputCount}
+	}
+	if err := checkFields(m, version, globalFieldVersions, required); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+func (in *Input) validate(version uint32) error {
+	for _, pair := range in.pairs {
+		keyType := pair.Key[0]
+		if v, ok := inputFieldVersions[keyType]; ok && v != version {
+			continue
+		}
+		keyLength := map[byte]int{
+			inNonWitnessUTXO: 1, inWitnessUTXO: 1, inSighashType: 1, inRedeemScript: 1, inWitnessScript: 1,
+			inFinalScriptSig: 1, inFinalScriptWitness: 1, inPORCommitment: 1,
+			inRipemd160: 21, inSha256: 33, inHash160: 21, inHash256: 33,
+			inPreviousTxID: 1, inOutputIndex: 1, inSequence: 1, inRequiredTimeLocktime: 1, inRequiredHeightLocktime: 1,
+			inTapKeySig: 1, inTapScriptSig: 65, inTapBIP32Derivation: 33, inTapInternalKey: 1, inTapMerkleRoot: 1,
+		}
+		valueLength := map[byte]int{
+			inSighashType: 4, inPreviousTxID: 32, inOutputIndex: 4, inSequence: 4,
+			inRequiredTimeLocktime: 4, inRequiredHeightLocktime: 4, inTapInternalKey: 32, inTapMerkleRoot: 32,
+		}
+		if length, ok := keyLength[keyType]; ok && len(pair.Key) != length {
+			return ErrorInvalidKey
+		}
+		if length, ok := valueLength[keyType]; ok && len(pair.Value) != length {
+			return ErrorInvalidValue
+		}
+		var err error
+		switch keyType {
+		case inNonWitnessUTXO:
+			_, err = DeserializeTx(pair.Value)
+		case inWitnessUTXO:
+			_, err = deserializeTxOut(pair.Value)
+		case inPartialSig:
+			if err = checkPublicKey(pair.Key[1:]); err == nil && len(pair.Value) == 0 {
+				err = ErrorInvalidValue
+			}
+		case inBIP32Derivation:
+			if err = checkPublicKey(pair.Key[1:]); err == nil {
+				_, err = parseKeyOrigin(pair.Value)
+			}
+		case inFinalScriptWitness:
+			err = checkWitness(pair.Value)
+		case inRipemd160, inSha256, inHash160, inHash256:
+			if !bytes.Equal(preimageHash(keyType, pair.Value), pair.Key[1:]) {
+				err = ErrorInvalidValue
+			}
+		case inRequiredTimeLocktime:
+			if binary.LittleEndian.Uint32(pair.Value) < locktimeThreshold {
+				err = ErrorInvalidValue
+			}
+		case inRequiredHeightLocktime:
+			if locktime := binary.LittleEndian.Uint32(pair.Value); locktime == 0 || locktime >= locktimeThreshold {
+				err = ErrorInvalidValue
+			}
+		case inTapKeySig, inTapScriptSig:
+			if len(pair.Value) != 64 && len(pair.Value) != 65 {
+				err = ErrorInvalidValue
+			} else if keyType == inTapScriptSig {
+				err = checkXOnlyPublicKey(pair.Key[1:33])
+			}
+		case inTapLeafScript:
+			// control block: leaf version and parity, internal key and the Merkle path
+			controlBlock := pair.Key[1:]
+			if len(controlBlock) < 33 || (len(controlBlock)-33)%32 != 0 || (len(controlBlock)-33)/32 > 128 {
+				err = ErrorInvalidKey
+			} else if len(pair.Value) == 0 {
+				err = ErrorInvalidValue
+			}
+		case inTapBIP32Derivation:
+			if err = checkXOnlyPublicKey(pair.Key[1:]); err == nil {
+				_, _, err = parseTapDerivationValue(pair.Value)
+			}
+		case inTapInternalKey:
+			err = checkXOnlyPublicKey(pair.Value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var required []byte
+	if version == 2 {
+		required = []byte{inPreviousTxID, inOutputIndex}
+	}
+	return checkFields(&in.Map, version, inputFieldVersions, required)
+}
+
+// checkUTXO checks that the non-witness UTXO of the input at index matches its outpoint.
+// unsignedTx is nil for version 2, whose outpoints are in the input maps.
+func (in *Input) checkUTXO(unsignedTx *Tx, index int) error {
+	prev := in.NonWitnessUTXO()
+	if prev == nil {
+		return nil
+	}
+	txID, outputIndex := in.outpoint(unsignedTx, index)
+	if prev.TxID() != txID || int(outputIndex) >= len(prev.Outputs) {
+		return ErrorUTXOMismatch
+	}
+	return nil
+}
+
+// outpoint returns the outpoint spent by the input at index.
+func (in *Input) outpoint(unsignedTx *Tx, index int) ([32]byte, uint32) {
+	if unsignedTx != nil {
+		return unsignedTx.Inputs[index].PreviousTxID, unsignedTx.Inputs[index].PreviousIndex
+	}
+	txID, _ := in.get(inPreviousTxID)
+	outputIndex, _ := in.get(inOutputIndex)
+	return [32]byte(txID), binary.LittleEndian.Uint32(outputIndex)
+}
+
+func (out *Output) validate(version uint32) error {
+	for _, pair := range out.pairs {
+		keyType := pair.Key[0]
+		if v, ok := outputFieldVersions[keyType]; ok && v != version {
+			continue
+		}
+		switch keyType {
+		case outRedeemScript, outWitnessScript, outAmount, outScript, outTapInternalKey, outTapTree:
+			if len(pair.Key) != 1 {
+				return ErrorInvalidKey
+			}
+		case outTapBIP32Derivation:
+			if len(pair.Key) != 33 {
+				return ErrorInvalidKey
+			}
+		}
+		var err error
+		switch keyType {
+		case outBIP32Derivation:
+			if err = checkPublicKey(pair.Key[1:]); err == nil {
+				_, err = parseKeyOrigin(pair.Value)
+			}
+		case outAmount:
+			if len(pair.Value) != 8 {
+				err = ErrorInvalidValue
+			}
+		case outTapInternalKey:
+			err = checkXOnlyPublicKey(pair.Value)
+		case outTapTree:
+			err = checkTapTree(pair.Value)
+		case outTapBIP32Derivation:
+			if err = checkXOnlyPublicKey(pair.Key[1:]); err == nil {
+				_, _, err = parseTapDerivationValue(pair.Value)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	var required []byte
+	if version == 2 {
+		required = []byte{outAmount, outScript}
+	}
+	return checkFields(&out.Map, version, outputFieldVersions, required)
+}
+
+func deserializeTxOut(data []byte) (*TxOut, error) {
+	r := reader{data: data}
+	out := TxOut{Amount: int64(r.readUint64()), ScriptPubKey: r.readVarBytes()}
+	if r.err != nil || len(r.data) != 0 {
+		return nil, ErrorInvalidValue
+	}
+	return &out, nil
+}
+
+func checkWitness(data []byte) error {
+	r := reader{data: data}
+	n := r.readCompactSize()
+	for i := uint64(0); i < n && r.err == nil; i++ {
+		r.readVarBytes()
+	}
+	if r.err != nil || len(r.data) != 0 {
+		return ErrorInvalidValue
+	}
+	return nil
+}
+
+// checkTapTree checks PSBT_OUT_TAP_TREE: a list of (depth, leaf version, script) which forms a complete binary tree.
+func checkTapTree(data []byte) error {
+	r := reader{data: data}
+	// depths of the unfilled positions; a complete tree fills all of them
+	var open []byte
+	first := true
+	for len(r.data) > 0 {
+		depth := r.readByte()
+		r.readByte()
+		r.readVarBytes()
+		if r.err != nil || depth > 128 {
+			return ErrorInvalidValue
+		}
+		if first {
+			open = []byte{0}
+			first = false
+		}
+		if len(open) == 0 {
+			return ErrorInvalidValue
+		}
+		// split the leftmost open position until it reaches depth
+		for open[0] < depth {
+			d := open[0] + 1
+			open = append([]byte{d, d}, open[1:]...)
+		}
+		if open[0] != depth {
+			return ErrorInvalidValue
+		}
+		open = open[1:]
+	}
+	if first || len(open) != 0 {
+		return ErrorInvalidValue
+	}
+	return nil
+}
+
+// UnsignedTx returns the unsigned transaction. For version 2, it is constructed from the fields
+// and its locktime is determined as specified in BIP 370.
+func (p *Packet) UnsignedTx() (*Tx, error) {
+	if p.Version() == 0 {
+		value, _ := p.Global.get(globalUnsignedTx)
+		// validated in Deserialize
+		return deserializeTx(value, false)
+	}
+	tx := Tx{}
+	if value, ok := p.Global.get(globalTxVersion); ok {
+		tx.Version = binary.LittleEndian.Uint32(value)
+	}
+	for i, in := range p.Inputs {
+		txIn := TxIn{Sequence: 0xffffffff}
+		txIn.PreviousTxID, txIn.PreviousIndex = in.outpoint(nil, i)
+		if value, ok := in.get(inSequence); ok {
+			txIn.Sequence = binary.LittleEndian.Uint32(value)
+		}
+		tx.Inputs = append(tx.Inputs, txIn)
+	}
+	for _, out := range p.Outputs {
+		txOut := TxOut{}
+		if value, ok := out.get(outAmount); ok {
+			txOut.Amount = int64(binary.LittleEndian.Uint64(value))
+		}
+		txOut.ScriptPubKey, _ = out.get(outScript)
+		tx.Outputs = append(tx.Outputs, txOut)
+	}
+	lockTime, err := p.lockTime()
+	if err != nil {
+		return nil, err
+	}
+	tx.LockTime = lockTime
+	return &tx, nil
+}
+
+// lockTime determines the locktime of a version 2 PSBT (BIP 370).
+func (p *Packet) lockTime() (uint32, error) {
+	// whether all inputs with locktime requirements accept a height-based or a time-based locktime
+	heightOK, timeOK := true, true
+	var maxHeight, maxTime uint32
+	hasRequirement := false
+	for _, in := range p.Inputs {
+		height, hasHeight := in.get(inRequiredHeightLocktime)
+		time, hasTime := in.get(inRequiredTimeLocktime)
+		if !hasHeight && !hasTime {
+			continue
+		}
+		hasRequirement = true
+		if hasHeight {
+			maxHeight = max(maxHeight, binary.LittleEndian.Uint32(height))
+		} else {
+			heightOK = false
+		}
+		if hasTime {
+			maxTime = max(maxTime, binary.LittleEndian.Uint32(time))
+		} else {
+			timeOK = false
+		}
+	}
+	switch {
+	case !hasRequirement:
+		if value, ok := p.Global.get(globalFallbackLocktime); ok {
+			return binary.LittleEndian.Uint32(value), nil
+		}
+		return 0, nil
+	case heightOK:
+		return maxHeight, nil
+	case timeOK:
+		return maxTime, nil
+	}
+	return 0, ErrorLocktimeConflict
+}
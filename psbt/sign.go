@@ -0,0 +1,104 @@
+package psbt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	bip32 "github.com/koba-e964/bip32-typesafe"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+)
+
+// Sign adds partial signatures to every input with a PSBT_IN_BIP32_DERIVATION entry whose key is derived from key.
+// It returns the number of signatures added. Entries that already have a partial signature are skipped.
+//
+// P2PKH, P2WPKH, P2WSH and P2SH-wrapped P2WPKH and P2WSH inputs are supported.
+// ErrorMissingUTXO is returned for non-witness inputs without PSBT_IN_NON_WITNESS_UTXO, as the BIP 174 signer algorithm requires.
+// Taproot inputs (PSBT_IN_TAP_BIP32_DERIVATION) are not signed; use TapDerivation.Derive to find their keys.
+func (p *Packet) Sign(key *bip32.PrivateKey) (int, error) {
+	tx, err := p.UnsignedTx()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for i, in := range p.Inputs {
+		signatures := in.PartialSignatures()
+		var keys []*bip32.PrivateKey
+		for _, d := range in.BIP32Derivations() {
+			if _, ok := signatures[d.PublicKey]; ok {
+				continue
+			}
+			child, err := d.Derive(key)
+			if errors.Is(err, ErrorNoMatchingKey) || errors.Is(err, ErrorKeyMismatch) {
+				continue
+			}
+			if err != nil {
+				return count, err
+			}
+			keys = append(keys, child)
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		hashType, err := in.SighashType()
+		if err != nil {
+			return count, err
+		}
+		hash, err := in.signatureHash(tx, i, hashType)
+		if err != nil {
+			return count, err
+		}
+		for _, child := range keys {
+			signature, err := secp256k1.ECDSASign(child.SecretPrivateKey(), hash)
+			if err != nil {
+				return count, err
+			}
+			if err := in.AddPartialSignature(child.GetPublicKey().PublicKey(), signature, hashType); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// signatureHash computes the signature hash of the input at index of tx.
+func (in *Input) signatureHash(tx *Tx, index int, hashType uint32) ([32]byte, error) {
+	var utxo *TxOut
+	prev := in.NonWitnessUTXO()
+	if prev != nil {
+		outputIndex := tx.Inputs[index].PreviousIndex
+		if int(outputIndex) >= len(prev.Outputs) {
+			return [32]byte{}, ErrorUTXOMismatch
+		}
+		utxo = &prev.Outputs[outputIndex]
+	} else if utxo = in.WitnessUTXO(); utxo == nil {
+		return [32]byte{}, ErrorMissingUTXO
+	}
+	script := utxo.ScriptPubKey
+	if isP2SH(script) {
+		redeemScript := in.RedeemScript()
+		if !bytes.Equal(hash160(redeemScript), script[2:22]) {
+			return [32]byte{}, ErrorScriptMismatch
+		}
+		script = redeemScript
+	}
+	switch {
+	case isP2WPKH(script):
+		return witnessV0SignatureHash(tx, index, p2pkhScriptCode(script[2:]), utxo.Amount, hashType), nil
+	case isP2WSH(script):
+		witnessScript := in.WitnessScript()
+		hash := sha256.Sum256(witnessScript)
+		if !bytes.Equal(hash[:], script[2:]) {
+			return [32]byte{}, ErrorScriptMismatch
+		}
+		return witnessV0SignatureHash(tx, index, witnessScript, utxo.Amount, hashType), nil
+	case isWitnessProgram(script):
+		return [32]byte{}, ErrorUnsupportedScript
+	}
+	// the amount is not signed in legacy inputs, so the witness UTXO can't be trusted (BIP 174 signer algorithm)
+	if prev == nil {
+		return [32]byte{}, ErrorMissingUTXO
+	}
+	return legacySignatureHash(tx, index, script, hashType), nil
+}
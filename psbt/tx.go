@@ -0,0 +1,354 @@
+package psbt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Signature hash types.
+const (
+	SighashAll          = 0x01
+	SighashNone         = 0x02
+	SighashSingle       = 0x03
+	SighashAnyoneCanPay = 0x80
+)
+
+// Tx is a Bitcoin transaction.
+type Tx struct {
+	Version  uint32
+	Inputs   []TxIn
+	Outputs  []TxOut
+	LockTime uint32
+}
+
+// TxIn is an input of a Tx.
+type TxIn struct {
+	PreviousTxID  [32]byte // in the byte order used in transactions, which is the reverse of the usual hex representation
+	PreviousIndex uint32
+	ScriptSig     []byte
+	Sequence      uint32
+	Witness       [][]byte
+}
+
+// TxOut is an output of a Tx.
+type TxOut struct {
+	Amount       int64 // in satoshis
+	ScriptPubKey []byte
+}
+
+// reader reads Bitcoin-serialized data. Once an error occurs, all subsequent reads fail.
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) read(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.data)) < n {
+		r.err = ErrorUnexpectedEOF
+		return nil
+	}
+	result := r.data[:n]
+	r.data = r.data[n:]
+	return result
+}
+
+func (r *reader) readByte() byte {
+	b := r.read(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *reader) readUint32() uint32 {
+	b := r.read(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *reader) readUint64() uint64 {
+	b := r.read(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// readCompactSize reads a CompactSize integer, which must be minimally encoded.
+func (r *reader) readCompactSize() uint64 {
+	first := r.readByte()
+	var result, minimum uint64
+	switch first {
+	case 0xfd:
+		b := r.read(2)
+		if b == nil {
+			return 0
+		}
+		result, minimum = uint64(binary.LittleEndian.Uint16(b)), 0xfd
+	case 0xfe:
+		result, minimum = uint64(r.readUint32()), 0x10000
+	case 0xff:
+		result, minimum = r.readUint64(), 0x100000000
+	default:
+		return uint64(first)
+	}
+	if r.err == nil && result < minimum {
+		r.err = ErrorNonCanonicalCompactSize
+	}
+	return result
+}
+
+func (r *reader) readVarBytes() []byte {
+	n := r.readCompactSize()
+	return r.read(n)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return binary.LittleEndian.AppendUint32(b, v)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return binary.LittleEndian.AppendUint64(b, v)
+}
+
+func appendCompactSize(b []byte, n uint64) []byte {
+	switch {
+	case n < 0xfd:
+		return append(b, byte(n))
+	case n <= 0xffff:
+		return binary.LittleEndian.AppendUint16(append(b, 0xfd), uint16(n))
+	case n <= 0xffffffff:
+		return appendUint32(append(b, 0xfe), uint32(n))
+	}
+	return appendUint64(append(b, 0xff), n)
+}
+
+func appendVarBytes(b []byte, data []byte) []byte {
+	return append(appendCompactSize(b, uint64(len(data))), data...)
+}
+
+// DeserializeTx parses a transaction in either the legacy or the segwit (BIP 144) serialization.
+func DeserializeTx(data []byte) (*Tx, error) {
+	return deserializeTx(data, true)
+}
+
+// deserializeTx parses a transaction. If allowWitness is false, the segwit serialization is not recognized;
+// this is needed for unsigned transactions without inputs, whose legacy serialization looks like the segwit marker.
+func deserializeTx(data []byte, allowWitness bool) (*Tx, error) {
+	r := reader{data: data}
+	tx := Tx{Version: r.readUint32()}
+	hasWitness := false
+	if allowWitness && len(r.data) >= 2 && r.data[0] == 0x00 && r.data[1] == 0x01 {
+		hasWitness = true
+		r.read(2)
+	}
+	numInputs := r.readCompactSize()
+	if numInputs > uint64(len(r.data)) {
+		return nil, ErrorInvalidTransaction
+	}
+	tx.Inputs = make([]TxIn, numInputs)
+	for i := range tx.Inputs {
+		in := &tx.Inputs[i]
+		copy(in.PreviousTxID[:], r.read(32))
+		in.PreviousIndex = r.readUint32()
+		in.ScriptSig = r.readVarBytes()
+		in.Sequence = r.readUint32()
+	}
+	numOutputs := r.readCompactSize()
+	if numOutputs > uint64(len(r.data)) {
+		return nil, ErrorInvalidTransaction
+	}
+	tx.Outputs = make([]TxOut, numOutputs)
+	for i := range tx.Outputs {
+		out := &tx.Outputs[i]
+		out.Amount = int64(r.readUint64())
+		out.ScriptPubKey = r.readVarBytes()
+	}
+	if hasWitness {
+		for i := range tx.Inputs {
+			numItems := r.readCompactSize()
+			if numItems > uint64(len(r.data)) {
+				return nil, ErrorInvalidTransaction
+			}
+			for j := uint64(0); j < numItems; j++ {
+				tx.Inputs[i].Witness = append(tx.Inputs[i].Witness, r.readVarBytes())
+			}
+		}
+	}
+	tx.LockTime = r.readUint32()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.data) != 0 {
+		return nil, ErrorInvalidTransaction
+	}
+	return &tx, nil
+}
+
+func (tx *Tx) hasWitness() bool {
+	for _, in := range tx.Inputs {
+		if len(in.Witness) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Serialize returns the serialization of tx. The segwit serialization (BIP 144) is used if tx has witnesses.
+func (tx *Tx) Serialize() []byte {
+	return tx.serialize(tx.hasWitness())
+}
+
+func (tx *Tx) serialize(withWitness bool) []byte {
+	b := appendUint32(nil, tx.Version)
+	if withWitness {
+		b = append(b, 0x00, 0x01)
+	}
+	b = appendCompactSize(b, uint64(len(tx.Inputs)))
+	for _, in := range tx.Inputs {
+		b = append(b, in.PreviousTxID[:]...)
+		b = appendUint32(b, in.PreviousIndex)
+		b = appendVarBytes(b, in.ScriptSig)
+		b = appendUint32(b, in.Sequence)
+	}
+	b = appendCompactSize(b, uint64(len(tx.Outputs)))
+	for _, out := range tx.Outputs {
+		b = out.append(b)
+	}
+	if withWitness {
+		for _, in := range tx.Inputs {
+			b = appendCompactSize(b, uint64(len(in.Witness)))
+			for _, item := range in.Witness {
+				b = appendVarBytes(b, item)
+			}
+		}
+	}
+	return appendUint32(b, tx.LockTime)
+}
+
+func (out *TxOut) append(b []byte) []byte {
+	b = appendUint64(b, uint64(out.Amount))
+	return appendVarBytes(b, out.ScriptPubKey)
+}
+
+// TxID returns the transaction ID in the byte order used in transactions.
+func (tx *Tx) TxID() [32]byte {
+	return doubleSHA256(tx.serialize(false))
+}
+
+func doubleSHA256(data []byte) [32]byte {
+	first := sha256.Sum256(data)
+	return sha256.Sum256(first[:])
+}
+
+// legacySignatureHash computes the signature hash of the input at index for scripts other than segwit programs.
+// OP_CODESEPARATOR in scriptCode is not handled.
+func legacySignatureHash(tx *Tx, index int, scriptCode []byte, hashType uint32) [32]byte {
+	baseType := hashType & 0x1f
+	if baseType == SighashSingle && index >= len(tx.Outputs) {
+		// the infamous SIGHASH_SINGLE bug: the hash is 1
+		return [32]byte{1}
+	}
+	anyoneCanPay := hashType&SighashAnyoneCanPay != 0
+	tmp := Tx{Version: tx.Version, LockTime: tx.LockTime}
+	for i, in := range tx.Inputs {
+		if anyoneCanPay && i != index {
+			continue
+		}
+		in.Witness = nil
+		in.ScriptSig = nil
+		if i == index {
+			in.ScriptSig = scriptCode
+		} else if baseType == SighashNone || baseType == SighashSingle {
+			in.Sequence = 0
+		}
+		tmp.Inputs = append(tmp.Inputs, in)
+	}
+	switch baseType {
+	case SighashNone:
+	case SighashSingle:
+		for i := 0; i < index; i++ {
+			tmp.Outputs = append(tmp.Outputs, TxOut{Amount: -1})
+		}
+		tmp.Outputs = append(tmp.Outputs, tx.Outputs[index])
+	default:
+		tmp.Outputs = tx.Outputs
+	}
+	return doubleSHA256(appendUint32(tmp.serialize(false), hashType))
+}
+
+// witnessV0SignatureHash computes the signature hash of the input at index for segwit version 0 programs (BIP 143).
+func witnessV0SignatureHash(tx *Tx, index int, scriptCode []byte, amount int64, hashType uint32) [32]byte {
+	baseType := hashType & 0x1f
+	anyoneCanPay := hashType&SighashAnyoneCanPay != 0
+	var hashPrevouts, hashSequence, hashOutputs [32]byte
+	if !anyoneCanPay {
+		var prevouts, sequences []byte
+		for _, in := range tx.Inputs {
+			prevouts = appendUint32(append(prevouts, in.PreviousTxID[:]...), in.PreviousIndex)
+			sequences = appendUint32(sequences, in.Sequence)
+		}
+		hashPrevouts = doubleSHA256(prevouts)
+		if baseType != SighashSingle && baseType != SighashNone {
+			hashSequence = doubleSHA256(sequences)
+		}
+	}
+	switch {
+	case baseType != SighashSingle && baseType != SighashNone:
+		var outputs []byte
+		for _, out := range tx.Outputs {
+			outputs = out.append(outputs)
+		}
+		hashOutputs = doubleSHA256(outputs)
+	case baseType == SighashSingle && index < len(tx.Outputs):
+		hashOutputs = doubleSHA256(tx.Outputs[index].append(nil))
+	}
+	in := tx.Inputs[index]
+	b := appendUint32(nil, tx.Version)
+	b = append(b, hashPrevouts[:]...)
+	b = append(b, hashSequence[:]...)
+	b = append(b, in.PreviousTxID[:]...)
+	b = appendUint32(b, in.PreviousIndex)
+	b = appendVarBytes(b, scriptCode)
+	b = appendUint64(b, uint64(amount))
+	b = appendUint32(b, in.Sequence)
+	b = append(b, hashOutputs[:]...)
+	b = appendUint32(b, tx.LockTime)
+	b = appendUint32(b, hashType)
+	return doubleSHA256(b)
+}
+
+// script templates
+
+func isP2SH(script []byte) bool {
+	return len(script) == 23 && script[0] == 0xa9 && script[1] == 0x14 && script[22] == 0x87
+}
+
+func isP2WPKH(script []byte) bool {
+	return len(script) == 22 && script[0] == 0x00 && script[1] == 0x14
+}
+
+func isP2WSH(script []byte) bool {
+	return len(script) == 34 && script[0] == 0x00 && script[1] == 0x20
+}
+
+// isWitnessProgram returns true if script is a segwit output script of any version (BIP 141).
+func isWitnessProgram(script []byte) bool {
+	if len(script) < 4 || len(script) > 42 {
+		return false
+	}
+	if script[0] != 0x00 && (script[0] < 0x51 || script[0] > 0x60) {
+		return false
+	}
+	return int(script[1])+2 == len(script)
+}
+
+func p2pkhScriptCode(publicKeyHash []byte) []byte {
+	return bytes.Join([][]byte{{0x76, 0xa9, 0x14}, publicKeyHash, {0x88, 0xac}}, nil)
+}
@@ -3,8 +3,8 @@ package bip32
 import (
 	"crypto/subtle"
 	"encoding/binary"
+	"errors"
 
-	"github.com/koba-e964/base58-go"
 	"github.com/koba-e964/bip32-typesafe/secp256k1"
 )
 
@@ -16,6 +16,7 @@ type PublicKey struct {
 	childNumber       [4]byte
 	chainCode         [32]byte
 	publicKey         secp256k1.Compressed
+	origin            *KeyOrigin // nil if unknown; always nil for master keys, whose origin is computed on demand
 }
 
 // Depth returns the depth of this PublicKey. If the depth is 0, this key is a master key.
@@ -43,6 +44,65 @@ func (p *PublicKey) PublicKey() secp256k1.Compressed {
 	return p.publicKey
 }
 
+// UncompressedBytes returns the public key of secp256k1 in this PublicKey in the uncompressed format (0x04 + x + y),
+// which Ethereum and some legacy systems use.
+// ErrorInvalidPublicKey is returned if the public key is not on the curve, which is possible for keys made by MasterPublicKeyFromRaw.
+func (p *PublicKey) UncompressedBytes() (secp256k1.Uncompressed, error) {
+	point, err := p.publicKey.Uncompress()
+	if err != nil {
+		return secp256k1.Uncompressed{}, ErrorInvalidPublicKey
+	}
+	return point.SerializeUncompressed(), nil
+}
+
+// Fingerprint returns the fingerprint of this PublicKey, namely the first 4 bytes of HASH160 of the public key.
+// Child keys of this PublicKey have this value as their parent fingerprint.
+func (p *PublicKey) Fingerprint() [4]byte {
+	return fingerprint(p.publicKey)
+}
+
+// KeyOrigin returns the origin (the master key fingerprint and the derivation path) of this PublicKey.
+// It returns nil if the origin is unknown, which is the case for non-master keys that were deserialized and not given an origin with WithKeyOrigin.
+// Keys derived from a key with a known origin have their origin tracked automatically.
+func (p *PublicKey) KeyOrigin() *KeyOrigin {
+	if p.depth == 0 {
+		return &KeyOrigin{MasterFingerprint: p.Fingerprint()}
+	}
+	return p.origin.clone()
+}
+
+// WithKeyOrigin returns a copy of this PublicKey with the given origin.
+// ErrorKeyOriginMismatch is returned if the length of the path is not the depth of this PublicKey,
+// the last index of the path is not the child number of this PublicKey,
+// or this PublicKey is a master key and origin.MasterFingerprint is not its fingerprint.
+func (p *PublicKey) WithKeyOrigin(origin KeyOrigin) (*PublicKey, error) {
+	if err := checkKeyOrigin(origin, p.depth, p.childNumber, p.Fingerprint()); err != nil {
+		return nil, err
+	}
+	result := *p
+	result.origin = nil
+	if p.depth != 0 {
+		result.origin = origin.clone()
+	}
+	return &result, nil
+}
+
+// IsTestnet returns true if this PublicKey is for testnet (tpub), false if it is for mainnet (xpub).
+func (p *PublicKey) IsTestnet() bool {
+	return p.version == [4]byte(testnetPublicKeyVersion)
+}
+
+// WithNetwork returns a copy of this PublicKey for testnet (tpub) if testnet is true, for mainnet (xpub) otherwise.
+func (p *PublicKey) WithNetwork(testnet bool) *PublicKey {
+	result := *p
+	result.version = [4]byte(publicKeyVersion)
+	if testnet {
+		result.version = [4]byte(testnetPublicKeyVersion)
+	}
+	result.origin = p.origin.clone()
+	return &result
+}
+
 // Serialize returns the []byte representation of this PublicKey.
 func (p *PublicKey) Serialize() [KeyLengthInBytes]byte {
 	var result [KeyLengthInBytes]byte
@@ -71,28 +131,31 @@ func (p *PublicKey) B58Serialize() string {
 }
 
 // B58DeserializePublicKey decodes a base58-encoded string and
-// returns a PublicKey.
+// returns a PublicKey. The returned error is a *DecodeError.
+// A string with a character outside the base58 alphabet is rejected with ErrorInvalidEncoding.
 func B58DeserializePublicKey(encoded string) (*PublicKey, error) {
 	if len(encoded) != 111 {
-		return nil, ErrorInvalidKeyLength
+		return nil, newDecodeError(fieldLength, ErrorInvalidKeyLength)
+	}
+	var data [KeyLengthInBytes]byte
+	if base58DecodeCanonical(encoded, data[:]) != 1 {
+		return nil, newDecodeError(fieldEncoding, ErrorInvalidEncoding)
 	}
-	var data [82]byte
-	base58.Decode(encoded, data[:])
 	return DeserializePublicKey(data)
 }
 
 // DeserializePublicKey reads a []byte and
-// returns a PublicKey.
+// returns a PublicKey. The returned error is a *DecodeError.
 func DeserializePublicKey(data [KeyLengthInBytes]byte) (*PublicKey, error) {
 	p := PublicKey{}
 
 	chksum := checksum(data[:78])
 	if subtle.ConstantTimeCompare(data[78:], chksum[:]) != 1 {
-		return nil, ErrorChecksumMismatch
+		return nil, newDecodeError(fieldChecksum, ErrorChecksumMismatch)
 	}
 
 	if (subtle.ConstantTimeCompare(data[:4], publicKeyVersion) | subtle.ConstantTimeCompare(data[:4], testnetPublicKeyVersion)) != 1 {
-		return nil, ErrorInvalidVersion
+		return nil, newDecodeError(fieldVersion, ErrorInvalidVersion)
 	}
 	p.version = [4]byte(data[:4])
 
@@ -101,48 +164,54 @@ func DeserializePublicKey(data [KeyLengthInBytes]byte) (*PublicKey, error) {
 	copy(p.parentFingerprint[:], data[5:9])
 
 	if (subtle.ConstantTimeByteEq(p.depth, 0) & (subtle.ConstantTimeCompare(p.parentFingerprint[:], make([]byte, 4)) ^ 1)) == 1 {
-		return nil, ErrorZeroDepthAndNonZeroParentFingerprint
+		return nil, newDecodeError(fieldParentFingerprint, ErrorZeroDepthAndNonZeroParentFingerprint)
 	}
 
 	copy(p.childNumber[:], data[9:13])
 
 	if (subtle.ConstantTimeByteEq(p.depth, 0) & (subtle.ConstantTimeCompare(p.childNumber[:], make([]byte, 4)) ^ 1)) == 1 {
-		return nil, ErrorZeroDepthAndNonZeroIndex
+		return nil, newDecodeError(fieldChildNumber, ErrorZeroDepthAndNonZeroIndex)
 	}
 
 	copy(p.chainCode[:], data[13:45])
 
-	if (data[45] & 2) != 2 {
-		return nil, ErrorInvalidPublicKey
+	// the prefix must be 0x02 or 0x03; uncompressed (0x04) and hybrid (0x06, 0x07) points are not allowed
+	if (data[45] & 0xfe) != 2 {
+		return nil, newDecodeError(fieldKeyData, ErrorInvalidPublicKey)
 	}
 	copy(p.publicKey[:], data[45:78])
 
 	// checks if p.publicKey is valid
 	if _, err := p.publicKey.Uncompress(); err != nil {
-		return nil, ErrorInvalidPublicKey
+		return nil, newDecodeError(fieldKeyData, ErrorInvalidPublicKey)
 	}
 
 	return &p, nil
 }
 
-// NewChildKey derives a new child key from this PublicKey. The following errors may be returned:
+// NewChildKey derives a new child key from this PublicKey.
+// The returned error is a *DerivationError, whose Reason is one of the following errors:
 //   - ErrorHardenedPublicChildKey: if childIdx >= FirstHardenedChildIndex = 0x80000000
 //   - ErrorTooDeepKey: if this PublicKey has depth 255
-//   - ErrorInvalidPublicKey: if the derived public key satisfies parse_{256}(I_L) >= n (with probability < 2^{-127})
+//   - ErrorInvalidPublicKey: if the derived public key satisfies parse_{256}(I_L) >= n or K_i is the point at infinity (with probability < 2^{-127}),
+//     or the public key of this PublicKey is not on the curve, which is possible only with MasterPublicKeyFromRaw
 func (p *PublicKey) NewChildKey(childIdx uint32) (*PublicKey, error) {
+	return p.newChildKey(childIdx, childHMAC)
+}
+
+// newChildKey is NewChildKey with I_L and I_R computed by hmacFunc.
+func (p *PublicKey) newChildKey(childIdx uint32, hmacFunc childHMACFunc) (*PublicKey, error) {
 	if childIdx >= FirstHardenedChildIndex {
-		return nil, ErrorHardenedPublicChildKey
+		return nil, &DerivationError{Depth: p.depth, Index: childIdx, Reason: ErrorHardenedPublicChildKey}
 	}
 	if p.depth == 255 {
-		return nil, ErrorTooDeepKey
+		return nil, &DerivationError{Depth: p.depth, Index: childIdx, Reason: ErrorTooDeepKey}
 	}
 	uncompressed, err := p.publicKey.Uncompress()
 	if err != nil {
-		return nil, err
+		return nil, &DerivationError{Depth: p.depth, Index: childIdx, Reason: ErrorInvalidPublicKey}
 	}
-	l := hmacThing(p.chainCode, p.publicKey, childIdx)
-	ll := [32]byte(l[:32])
-	lr := [32]byte(l[32:])
+	ll, lr := hmacFunc(p.chainCode, p.publicKey, childIdx)
 	var derivedPubKey secp256k1.Point
 	var llPoint secp256k1.Point
 	llPoint.GEPoint(ll)
@@ -150,14 +219,44 @@ func (p *PublicKey) NewChildKey(childIdx uint32) (*PublicKey, error) {
 	child := PublicKey{
 		version:           p.version,
 		depth:             p.depth + 1,
-		parentFingerprint: [4]byte(hash160(p.publicKey[:])),
+		parentFingerprint: p.Fingerprint(),
 		childNumber:       uint32ToBytes(childIdx),
 		chainCode:         lr,
 		publicKey:         derivedPubKey.Compress(),
+		origin:            p.KeyOrigin().child(childIdx),
 	}
-	cmp := secp256k1.SCIsValid(ll)
+	cmp := secp256k1.SCIsValid(ll) & (derivedPubKey.IsInfinity() ^ 1)
 	if cmp != 1 {
-		return nil, ErrorInvalidPrivateKey
+		return nil, &DerivationError{Depth: p.depth, Index: childIdx, Reason: ErrorInvalidPublicKey}
 	}
 	return &child, nil
 }
+
+// NewChildKeySkipInvalid derives a new child key from this PublicKey.
+// Unlike NewChildKey, if the child key at childIdx is invalid, it proceeds with the next index as BIP 32 specifies.
+// It returns the derived key together with the index actually used.
+// The following errors may be returned:
+//   - ErrorHardenedPublicChildKey: if childIdx >= FirstHardenedChildIndex = 0x80000000
+//   - ErrorTooDeepKey: if this PublicKey has depth 255
+//   - ErrorInvalidPublicKey: if there is no valid child key in the rest of the non-hardened index range (practically impossible)
+func (p *PublicKey) NewChildKeySkipInvalid(childIdx uint32) (*PublicKey, uint32, error) {
+	return p.newChildKeySkipInvalid(childIdx, childHMAC)
+}
+
+// newChildKeySkipInvalid is NewChildKeySkipInvalid with I_L and I_R computed by hmacFunc.
+func (p *PublicKey) newChildKeySkipInvalid(childIdx uint32, hmacFunc childHMACFunc) (*PublicKey, uint32, error) {
+	for {
+		child, err := p.newChildKey(childIdx, hmacFunc)
+		if !errors.Is(err, ErrorInvalidPublicKey) {
+			if err != nil {
+				return nil, 0, err
+			}
+			return child, childIdx, nil
+		}
+		next, ok := nextChildIndex(childIdx)
+		if !ok {
+			return nil, 0, err
+		}
+		childIdx = next
+	}
+}
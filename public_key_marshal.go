@@ -0,0 +1,85 @@
+package bip32
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// The text, JSON and SQL representations of a PublicKey are B58Serialize and the binary representation is Serialize.
+// Unmarshaling validates the input exactly as DeserializePublicKey does.
+// The key origin is not part of any representation, so unmarshaled non-master keys have no origin.
+
+// MarshalText implements encoding.TextMarshaler.
+func (p *PublicKey) MarshalText() ([]byte, error) {
+	return []byte(p.B58Serialize()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *PublicKey) UnmarshalText(text []byte) error {
+	key, err := B58DeserializePublicKey(string(text))
+	if err != nil {
+		return err
+	}
+	*p = *key
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler. The result is 82 bytes long.
+func (p *PublicKey) MarshalBinary() ([]byte, error) {
+	data := p.Serialize()
+	return data[:], nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. The returned error is a *DecodeError;
+// its Reason is ErrorInvalidKeyLength if data is not 82 bytes long.
+func (p *PublicKey) UnmarshalBinary(data []byte) error {
+	if len(data) != KeyLengthInBytes {
+		return newDecodeError(fieldLength, ErrorInvalidKeyLength)
+	}
+	key, err := DeserializePublicKey([KeyLengthInBytes]byte(data))
+	if err != nil {
+		return err
+	}
+	*p = *key
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The result is a JSON string.
+func (p *PublicKey) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.B58Serialize())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. JSON null is ignored, as encoding/json does for other types.
+func (p *PublicKey) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	return p.UnmarshalText([]byte(encoded))
+}
+
+// Scan implements sql.Scanner. src may be the text representation as string or []byte, or the 82-byte binary representation.
+// ErrorUnsupportedScanType is returned for other types, including NULL.
+func (p *PublicKey) Scan(src any) error {
+	switch src := src.(type) {
+	case string:
+		return p.UnmarshalText([]byte(src))
+	case []byte:
+		if len(src) == KeyLengthInBytes {
+			return p.UnmarshalBinary(src)
+		}
+		return p.UnmarshalText(src)
+	}
+	return ErrorUnsupportedScanType
+}
+
+// Value implements driver.Valuer. It returns the text representation, or NULL if p is nil.
+func (p *PublicKey) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return p.B58Serialize(), nil
+}
@@ -0,0 +1,49 @@
+package bip32
+
+import (
+	"crypto/subtle"
+
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+)
+
+// RecoverParentPrivateKey recovers the parent private key from the parent public key and a non-hardened child private key.
+// Because k_i = parse_{256}(I_L) + k_par (mod n) and I_L only depends on the parent public key, the chain code and the index,
+// anyone who knows both of them can compute k_par = k_i - parse_{256}(I_L) (mod n).
+// This is why non-hardened child private keys must be kept as secret as their parents.
+//
+// The following errors may be returned:
+//   - ErrorHardenedChildKey: if child is a hardened child key
+//   - ErrorParentMismatch: if child's depth, parent fingerprint or network doesn't match parent, or the recovered private key doesn't correspond to parent
+//   - ErrorInvalidPrivateKey: if parse_{256}(I_L) >= n
+func RecoverParentPrivateKey(parent *PublicKey, child *PrivateKey) (*PrivateKey, error) {
+	childIdx := child.ChildNumber()
+	if childIdx >= FirstHardenedChildIndex {
+		return nil, ErrorHardenedChildKey
+	}
+	version := privateKeyVersion
+	if parent.version == [4]byte(testnetPublicKeyVersion) {
+		version = testnetPrivateKeyVersion
+	}
+	if child.version != [4]byte(version) || child.depth != parent.depth+1 || child.parentFingerprint != parent.Fingerprint() {
+		return nil, ErrorParentMismatch
+	}
+	ll, _ := childHMAC(parent.chainCode, parent.publicKey, childIdx)
+	if secp256k1.NewSecretScalar(ll).IsValid() != 1 {
+		return nil, ErrorInvalidPrivateKey
+	}
+	recovered := PrivateKey{
+		version:           [4]byte(version),
+		depth:             parent.depth,
+		parentFingerprint: parent.parentFingerprint,
+		childNumber:       parent.childNumber,
+		chainCode:         parent.chainCode,
+		privateKey:        child.privateKey.Sub(secp256k1.NewSecretScalar(ll)),
+		origin:            parent.origin.clone(),
+		public:            &publicPart{},
+	}
+	pubPartCompressed := recovered.publicKey()
+	if subtle.ConstantTimeCompare(pubPartCompressed[:], parent.publicKey[:]) != 1 {
+		return nil, ErrorParentMismatch
+	}
+	return &recovered, nil
+}
@@ -1,5 +1,7 @@
 // Package secp256k1 implements secp256k1-related functions and types.
-//   - the elliptic curve secp256k1 itself (Compressed, Point and functions with prefix GE)
+//   - the elliptic curve secp256k1 itself (Compressed, Uncompressed, Point and functions with prefix GE)
 //   - scalar values (Scalar and functions with prefix SC)
+//   - secret scalar values (SecretScalar), which allow only constant-time operations
+//   - ECDSA signatures (ECDSASignature, ECDSASign and ECDSAVerify)
 //   - utility functions
 package secp256k1
@@ -0,0 +1,42 @@
+//go:build dudect
+
+package secp256k1
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/koba-e964/bip32-typesafe/internal/dudect"
+)
+
+func randomBytes() [32]byte {
+	var result [32]byte
+	if _, err := rand.Read(result[:]); err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func TestDudectGEProjPoint(t *testing.T) {
+	result := dudect.Measure(*dudect.Measurements/100, 1, func() Scalar { return Scalar{31: 1} }, func() Scalar { return Scalar(randomBytes()) }, func(n Scalar) {
+		var p ProjPoint
+		p.GEProjPoint(n)
+	})
+	dudect.Report(t, result)
+}
+
+func TestDudectSCAdd(t *testing.T) {
+	result := dudect.Measure(*dudect.Measurements, 100, func() [2]Scalar { return [2]Scalar{} }, func() [2]Scalar {
+		return [2]Scalar{randomBytes(), randomBytes()}
+	}, func(input [2]Scalar) {
+		_ = SCAdd(input[0], input[1])
+	})
+	dudect.Report(t, result)
+}
+
+func TestDudectFeInv(t *testing.T) {
+	result := dudect.Measure(*dudect.Measurements/10, 1, func() fe { return fe{7: 1} }, func() fe { return feFromBytes(randomBytes()) }, func(a fe) {
+		_ = feInv(a)
+	})
+	dudect.Report(t, result)
+}
@@ -0,0 +1,192 @@
+package secp256k1
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+)
+
+var (
+	ErrorInvalidPrivateKey = errors.New("private key is out of range")
+	ErrorInvalidSignature  = errors.New("invalid DER signature")
+)
+
+// ECDSASignature is an ECDSA signature (r, s).
+type ECDSASignature struct {
+	R Scalar
+	S Scalar
+}
+
+// halfOrder = (Order - 1) / 2
+var halfOrder = func() Scalar {
+	var result Scalar
+	var carry byte
+	for i := 0; i < len(Order); i++ {
+		result[i] = carry<<7 | Order[i]>>1
+		carry = Order[i] & 1
+	}
+	return result
+}()
+
+// ECDSASign signs hash with privateKey. The nonce is generated deterministically as specified in RFC 6979
+// with HMAC-SHA256, and the signature has a low S (BIP 62).
+// It runs in constant-time with respect to privateKey.
+func ECDSASign(privateKey SecretScalar, hash [32]byte) (*ECDSASignature, error) {
+	if privateKey.IsValid()&^privateKey.IsZero() != 1 {
+		return nil, ErrorInvalidPrivateKey
+	}
+	var zeroScalar Scalar
+	// bits2octets(hash): hash is reduced mod Order
+	h := Scalar(hash)
+	scReduce(&h)
+	nonces := newRFC6979(privateKey.scalar, h)
+	for {
+		k := nonces.next()
+		var point Point
+		point.GEPoint(k)
+		compressed := point.Compress()
+		r := Scalar(compressed[1:])
+		scReduce(&r)
+		s := SCMul(SCInv(k), SCAdd(h, SCMul(r, privateKey.scalar)))
+		//vartime:public r and s are published as the signature
+		if r == zeroScalar || s == zeroScalar {
+			continue
+		}
+		isHigh := subtle.ConstantTimeEq(int32(CompareBytes(s, halfOrder)), 1)
+		negated := SCSub(zeroScalar, s)
+		for i := 0; i < len(s); i++ {
+			s[i] = byte(subtle.ConstantTimeSelect(isHigh, int(negated[i]), int(s[i])))
+		}
+		return &ECDSASignature{R: r, S: s}, nil
+	}
+}
+
+// rfc6979 generates nonces as specified in RFC 6979, Section 3.2.
+type rfc6979 struct {
+	k, v  [32]byte
+	first bool
+}
+
+func newRFC6979(privateKey Scalar, hash Scalar) *rfc6979 {
+	g := rfc6979{first: true}
+	for i := range g.v {
+		g.v[i] = 0x01
+	}
+	for _, b := range []byte{0x00, 0x01} {
+		mac := hmac.New(sha256.New, g.k[:])
+		mac.Write(g.v[:])
+		mac.Write([]byte{b})
+		mac.Write(privateKey[:])
+		mac.Write(hash[:])
+		g.k = [32]byte(mac.Sum(nil))
+		g.v = g.hmac(g.v[:])
+	}
+	return &g
+}
+
+func (g *rfc6979) hmac(data ...[]byte) [32]byte {
+	mac := hmac.New(sha256.New, g.k[:])
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return [32]byte(mac.Sum(nil))
+}
+
+// next returns the next candidate nonce in [1, Order).
+func (g *rfc6979) next() Scalar {
+	for {
+		if !g.first {
+			g.k = g.hmac(g.v[:], []byte{0x00})
+			g.v = g.hmac(g.v[:])
+		}
+		g.first = false
+		g.v = g.hmac(g.v[:])
+		var zeroScalar Scalar
+		// Rejection happens with negligible probability, so this branch doesn't leak information in practice.
+		//vartime:public
+		if SCIsValid(g.v) == 1 && g.v != zeroScalar {
+			return g.v
+		}
+	}
+}
+
+// ECDSAVerify returns true if sig is a valid signature of hash by publicKey.
+// High S values are accepted. It does not have a constant-time guarantee, because all inputs are public.
+//
+//vartime:public
+func ECDSAVerify(publicKey Compressed, hash [32]byte, sig *ECDSASignature) bool {
+	var zeroScalar Scalar
+	if sig.R == zeroScalar || sig.S == zeroScalar || SCIsValid(sig.R) != 1 || SCIsValid(sig.S) != 1 {
+		return false
+	}
+	q, err := publicKey.Uncompress()
+	if err != nil {
+		return false
+	}
+	h := Scalar(hash)
+	scReduce(&h)
+	sInv := SCInv(sig.S)
+	var u1G, u2Q, sum Point
+	u1G.GEPoint(SCMul(h, sInv))
+	u2Q.GEScalarMult(q, SCMul(sig.R, sInv))
+	sum.GEAdd(&u1G, &u2Q)
+	if sum.IsInfinity() == 1 {
+		return false
+	}
+	compressed := sum.Compress()
+	x := Scalar(compressed[1:])
+	scReduce(&x)
+	return x == sig.R
+}
+
+// DER returns the DER encoding of sig.
+func (sig *ECDSASignature) DER() []byte {
+	r := derInteger(sig.R)
+	s := derInteger(sig.S)
+	result := []byte{0x30, byte(len(r) + len(s))}
+	result = append(result, r...)
+	return append(result, s...)
+}
+
+// derInteger returns the DER encoding of a non-negative integer.
+func derInteger(a Scalar) []byte {
+	b := a[:]
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0x00}, b...)
+	}
+	return append([]byte{0x02, byte(len(b))}, b...)
+}
+
+// ParseDERSignature parses a strictly DER-encoded ECDSA signature (BIP 66).
+func ParseDERSignature(data []byte) (*ECDSASignature, error) {
+	if len(data) < 8 || len(data) > 72 || data[0] != 0x30 || int(data[1]) != len(data)-2 {
+		return nil, ErrorInvalidSignature
+	}
+	var sig ECDSASignature
+	rest := data[2:]
+	for _, target := range []*Scalar{&sig.R, &sig.S} {
+		if len(rest) < 2 || rest[0] != 0x02 || int(rest[1]) > len(rest)-2 || rest[1] == 0 {
+			return nil, ErrorInvalidSignature
+		}
+		b := rest[2 : 2+rest[1]]
+		rest = rest[2+rest[1]:]
+		if b[0]&0x80 != 0 || (len(b) > 1 && b[0] == 0 && b[1]&0x80 == 0) {
+			return nil, ErrorInvalidSignature
+		}
+		if b[0] == 0 {
+			b = b[1:]
+		}
+		if len(b) > 32 {
+			return nil, ErrorInvalidSignature
+		}
+		copy(target[32-len(b):], b)
+	}
+	if len(rest) != 0 {
+		return nil, ErrorInvalidSignature
+	}
+	return &sig, nil
+}
@@ -0,0 +1,67 @@
+package secp256k1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestECDSASign(t *testing.T) {
+	tests := []struct {
+		privateKey string
+		message    string
+		r          string
+		s          string
+	}{
+		// https://github.com/bitcoinjs/bitcoinjs-lib/blob/v6.1.0/test/fixtures/ecdsa.json (RFC 6979 with low S)
+		{privateKey: "0000000000000000000000000000000000000000000000000000000000000001", message: "Satoshi Nakamoto", r: "934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d8", s: "2442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5"},
+		{privateKey: "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364140", message: "Satoshi Nakamoto", r: "fd567d121db66e382991534ada77a6bd3106f0a1098c231e47993447cd6af2d0", s: "6b39cd0eb1bc8603e159ef5c20a5c8ad685a45b06ce9bebed3f153d10d93bed5"},
+		{privateKey: "fb26a4e75eec75544c0f44e937dcf5ee6355c7176600b9688c667e5c283b43c5", message: "Alan Turing", r: "316b171255ce8d25e8f2624e696811c878666b6028f8e17fc62a5e597253278b", s: "02399ef396ec5e568fc07d628298632057c231eb00270bc833cbc630a52b4fe5"},
+	}
+	for _, test := range tests {
+		privateKey := Scalar(suppress(hex.DecodeString(test.privateKey)))
+		hash := sha256.Sum256([]byte(test.message))
+		sig, err := ECDSASign(NewSecretScalar(privateKey), hash)
+		assert.Nil(t, err)
+		assert.Equal(t, test.r, hex.EncodeToString(sig.R[:]))
+		assert.Equal(t, test.s, hex.EncodeToString(sig.S[:]))
+
+		var point Point
+		point.GEPoint(privateKey)
+		publicKey := point.Compress()
+		assert.True(t, ECDSAVerify(publicKey, hash, sig))
+		hash[0] ^= 1
+		assert.False(t, ECDSAVerify(publicKey, hash, sig))
+
+		parsed, err := ParseDERSignature(sig.DER())
+		assert.Nil(t, err)
+		assert.Equal(t, sig, parsed)
+	}
+	_, err := ECDSASign(SecretScalar{}, [32]byte{})
+	assert.Equal(t, ErrorInvalidPrivateKey, err)
+	_, err = ECDSASign(NewSecretScalar(Order), [32]byte{})
+	assert.Equal(t, ErrorInvalidPrivateKey, err)
+}
+
+func TestParseDERSignature(t *testing.T) {
+	valid := "3045022100934b1ea10a4b3c1757e2b0c017d0b6143ce3c9a7e6a4a49860d7a6ab210ee3d802202442ce9d2b916064108014783e923ec36b49743e2ffa1c4496f01a512aafd9e5"
+	sig, err := ParseDERSignature(suppress(hex.DecodeString(valid)))
+	assert.Nil(t, err)
+	assert.Equal(t, valid, hex.EncodeToString(sig.DER()))
+	invalid := []string{
+		// trailing garbage
+		valid + "00",
+		// negative r
+		"3044022093" + valid[12:],
+		// unnecessary padding of s
+		"3046" + valid[4:74] + "022100" + valid[78:],
+		// empty
+		"",
+	}
+	for _, s := range invalid {
+		_, err := ParseDERSignature(suppress(hex.DecodeString(s)))
+		assert.Equal(t, ErrorInvalidSignature, err, s)
+	}
+}
@@ -2,6 +2,7 @@ package secp256k1
 
 import (
 	"encoding/hex"
+	"math/big"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -206,3 +207,39 @@ func BenchmarkModSqrt_ConstantTime(b *testing.B) {
 		feModSqrt(value)
 	}
 }
+
+// bigFromBytes returns b mod p.
+func bigFromBytes(b []byte) *big.Int {
+	return new(big.Int).Mod(new(big.Int).SetBytes(b), pBig)
+}
+
+func feFromBig(a *big.Int) fe {
+	var b [32]byte
+	a.FillBytes(b[:])
+	return feFromBytes(b)
+}
+
+// FuzzFE compares the field arithmetic with math/big.
+func FuzzFE(f *testing.F) {
+	f.Add(make([]byte, 32), make([]byte, 32))
+	f.Add(pBytes, pBytes)
+	// the x-coordinates of the public keys of the BIP 32 test vector 1 (m, m/0H)
+	f.Add(suppress(hex.DecodeString("39a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2")), suppress(hex.DecodeString("5a784662a4a20a65bf6aab9ae98a6c068a81c52e4b032c0fb5400c706cfccc56")))
+	f.Add(suppress(hex.DecodeString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2e")), suppress(hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000001")))
+	exponentInv := new(big.Int).Sub(pBig, big.NewInt(2))
+	exponentSqrt := new(big.Int).Rsh(new(big.Int).Add(pBig, big.NewInt(1)), 2)
+	f.Fuzz(func(t *testing.T, aBytes []byte, bBytes []byte) {
+		if len(aBytes) != 32 || len(bBytes) != 32 {
+			return
+		}
+		aBig, bBig := bigFromBytes(aBytes), bigFromBytes(bBytes)
+		a, b := feFromBig(aBig), feFromBig(bBig)
+		assert.Equal(t, feFromBig(new(big.Int).Mod(new(big.Int).Add(aBig, bBig), pBig)), feAdd(a, b))
+		assert.Equal(t, feFromBig(new(big.Int).Mod(new(big.Int).Sub(aBig, bBig), pBig)), feSub(a, b))
+		assert.Equal(t, feFromBig(new(big.Int).Mod(new(big.Int).Mul(aBig, bBig), pBig)), feMul(a, b))
+		assert.Equal(t, feFromBig(new(big.Int).Mod(new(big.Int).Mul(aBig, aBig), pBig)), feSquare(a))
+		assert.Equal(t, feFromBig(new(big.Int).Mod(new(big.Int).Mul(aBig, big.NewInt(21)), pBig)), feMul21(a))
+		assert.Equal(t, feFromBig(new(big.Int).Exp(aBig, exponentInv, pBig)), feInv(a))
+		assert.Equal(t, feFromBig(new(big.Int).Exp(aBig, exponentSqrt, pBig)), feModSqrt(a))
+	})
+}
@@ -0,0 +1,56 @@
+package secp256k1
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+const redactedScalar = "secp256k1.Scalar(REDACTED)"
+
+// String returns a redacted representation of this Scalar, because a Scalar is often a private key.
+// To export the value explicitly, use its bytes, e.g. hex.EncodeToString(s[:]).
+// Note that the components of an ECDSASignature are also redacted; use DER to export signatures.
+//
+// fmt does not call methods of unexported struct fields, so types that hold a Scalar in an unexported field
+// should redact it themselves, as bip32.PrivateKey does.
+func (s Scalar) String() string {
+	return redactedScalar
+}
+
+// GoString returns a redacted representation of this Scalar, which is used by %#v.
+func (s Scalar) GoString() string {
+	return redactedScalar
+}
+
+// Format implements fmt.Formatter. It writes a redacted representation for any verb, including %x and %d.
+func (s Scalar) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, redactedScalar)
+}
+
+// LogValue implements slog.LogValuer. It returns a redacted value.
+func (s Scalar) LogValue() slog.Value {
+	return slog.StringValue("REDACTED")
+}
+
+const redactedSecretScalar = "secp256k1.SecretScalar(REDACTED)"
+
+// String returns a redacted representation of this SecretScalar. To export the value explicitly, use Declassify.
+func (a SecretScalar) String() string {
+	return redactedSecretScalar
+}
+
+// GoString returns a redacted representation of this SecretScalar, which is used by %#v.
+func (a SecretScalar) GoString() string {
+	return redactedSecretScalar
+}
+
+// Format implements fmt.Formatter. It writes a redacted representation for any verb.
+func (a SecretScalar) Format(f fmt.State, verb rune) {
+	_, _ = io.WriteString(f, redactedSecretScalar)
+}
+
+// LogValue implements slog.LogValuer. It returns a redacted value.
+func (a SecretScalar) LogValue() slog.Value {
+	return slog.StringValue("REDACTED")
+}
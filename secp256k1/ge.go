@@ -18,6 +18,11 @@ var ErrorInvalidPoint = errors.New("invalid point on secp256k1")
 // Its zero value is invalid. It cannot represent the infinity (zero element).
 type Compressed [33]byte
 
+// Uncompressed is an uncompressed (65-byte, 0x04 + x-coordinate + y-coordinate) representation of a point on secp256k1,
+// as specified in SEC 1, section 2.3.3.
+// Its zero value is invalid. It cannot represent the infinity (zero element).
+type Uncompressed [65]byte
+
 var (
 	gxBytes, _    = hex.DecodeString("79BE667EF9DCBBAC55A06295CE870B07029BFCDB2DCE28D959F2815B16F81798")
 	gyBytes, _    = hex.DecodeString("483ADA7726A3C4655DA4FBFC0E1108A8FD17B448A68554199C47D08FFB10D4B8")
@@ -121,6 +126,69 @@ func (a Compressed) UncompressProj() (*ProjPoint, error) {
 	return (*ProjPoint)(result), err
 }
 
+// Decode returns the point a represents. ErrorInvalidPoint is returned if the prefix of a is not 0x04,
+// a coordinate is not less than P or the point is not on the curve.
+// It does not have a constant-time guarantee, because points are public.
+func (a Uncompressed) Decode() (*Point, error) {
+	if a[0] != 0x04 {
+		return nil, ErrorInvalidPoint
+	}
+	return pointFromCoordinates([32]byte(a[1:33]), [32]byte(a[33:]))
+}
+
+// Compress returns a in the compressed format. The same errors as Decode may be returned.
+func (a Uncompressed) Compress() (Compressed, error) {
+	point, err := a.Decode()
+	if err != nil {
+		return Compressed{}, err
+	}
+	return point.Compress(), nil
+}
+
+// pointFromCoordinates returns the point (x, y) in affine coordinates, checking that it is on the curve.
+func pointFromCoordinates(xBytes [32]byte, yBytes [32]byte) (*Point, error) {
+	x := feFromBytes(xBytes)
+	y := feFromBytes(yBytes)
+	if feIsValid(x)&feIsValid(y) != 1 {
+		return nil, ErrorInvalidPoint
+	}
+	// y^2 = x^3 + 7
+	var seven fe
+	seven[7] = 7
+	if CompareUint32s(feSquare(y), feAdd(feMul(feSquare(x), x), seven)) != 0 {
+		return nil, ErrorInvalidPoint
+	}
+	return &Point{x: x, y: y, z: one}, nil
+}
+
+// ParsePoint parses a point in the compressed (33 bytes) or the uncompressed (65 bytes) encoding of SEC 1,
+// checking that the point is on the curve. ErrorInvalidPoint is returned if data is invalid.
+// The hybrid encoding, whose prefix is 0x06 or 0x07, is rejected; use ParseHybridPoint to accept it.
+// It does not have a constant-time guarantee, because points are public.
+func ParsePoint(data []byte) (*Point, error) {
+	switch len(data) {
+	case len(Compressed{}):
+		return Compressed(data).Uncompress()
+	case len(Uncompressed{}):
+		return Uncompressed(data).Decode()
+	default:
+		return nil, ErrorInvalidPoint
+	}
+}
+
+// ParseHybridPoint is the same as ParsePoint, except that it also accepts the hybrid encoding of X9.62,
+// namely 0x06 or 0x07 + x-coordinate + y-coordinate, where the last bit of the prefix must be y mod 2.
+// The hybrid encoding is only produced by some legacy software; new code should not accept it.
+func ParseHybridPoint(data []byte) (*Point, error) {
+	if len(data) != len(Uncompressed{}) || data[0]&0xfe != 0x06 {
+		return ParsePoint(data)
+	}
+	if data[0]&1 != data[64]&1 {
+		return nil, ErrorInvalidPoint
+	}
+	return pointFromCoordinates([32]byte(data[1:33]), [32]byte(data[33:]))
+}
+
 // Compress returns the value in the compressed format. It runs in constant-time.
 func (p *JacobianPoint) Compress() Compressed {
 	var result [33]byte
@@ -147,6 +215,24 @@ func (p *ProjPoint) Compress() Compressed {
 	return result
 }
 
+// SerializeUncompressed returns the value in the uncompressed format. It runs in constant-time.
+// If p is the point at infinity, the result is invalid.
+func (p *ProjPoint) SerializeUncompressed() Uncompressed {
+	var result Uncompressed
+	zInv := feInv(p.z)
+	x := feMul(p.x, zInv).Bytes()
+	y := feMul(p.y, zInv).Bytes()
+	result[0] = 0x04
+	copy(result[1:33], x[:])
+	copy(result[33:], y[:])
+	return result
+}
+
+// IsInfinity returns 1 if p is the point at infinity (zero element), and 0 otherwise. It runs in constant-time.
+func (p *ProjPoint) IsInfinity() int {
+	return CompareUint32s(p.z, zero) ^ 1
+}
+
 func (p *ProjPoint) assertValid() {
 	tmp := feMul(feMul(p.y, p.y), p.z)
 	tmp = feSub(tmp, feMul(feMul(p.x, p.x), p.x))
@@ -343,6 +429,19 @@ func (p *Point) GEPoint(n Scalar) {
 	p.GEProjPoint(n)
 }
 
+// GEScalarMult computes n a. It runs in constant-time.
+func (p *ProjPoint) GEScalarMult(a *ProjPoint, n Scalar) {
+	result := ProjPoint{y: one}
+	var sum ProjPoint
+	for i := 0; i < 256; i++ {
+		result.GEProjDouble(&result)
+		sum.GEProjAdd(&result, a)
+		cond := int(n[i/8]>>(7-i%8)) & 1
+		result.choiceProjPoint(cond, &sum, &result)
+	}
+	*p = result
+}
+
 func GEJacobianPoint(n Scalar) *JacobianPoint {
 	prod := &JacobianPoint{x: one, y: one}
 	for i := 0; i < 256; i++ {
@@ -2,8 +2,10 @@ package secp256k1
 
 import (
 	"encoding/hex"
+	"math/big"
 	"testing"
 
+	btcutil "github.com/FactomProject/btcutilecc"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -82,6 +84,16 @@ func TestGEPoint3(t *testing.T) {
 	}
 }
 
+func TestIsInfinity(t *testing.T) {
+	var point Point
+	point.GEPoint(Order)
+	assert.Equal(t, 1, point.IsInfinity())
+	var one Scalar
+	one[31] = 1
+	point.GEPoint(one)
+	assert.Equal(t, 0, point.IsInfinity())
+}
+
 func BenchmarkGEJacobianPoint_VariableTime_Short(b *testing.B) {
 	var two Scalar
 	two[31] = 2
@@ -136,3 +148,185 @@ func BenchmarkGEProjPoint_ConstantTime_Short(b *testing.B) {
 		p.GEProjPoint(two)
 	}
 }
+
+func TestGEScalarMult(t *testing.T) {
+	var three, seven, twentyOne Scalar
+	three[31] = 3
+	seven[31] = 7
+	twentyOne[31] = 21
+	var a, result, expected Point
+	a.GEPoint(three)
+	result.GEScalarMult(&a, seven)
+	expected.GEPoint(twentyOne)
+	assert.Equal(t, expected.Compress(), result.Compress())
+	result.GEScalarMult(&a, Order)
+	assert.Equal(t, 1, result.IsInfinity())
+}
+
+func TestUncompressed(t *testing.T) {
+	g := Uncompressed(suppress(hex.DecodeString("0479be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8")))
+	var point Point
+	point.GEPoint(Scalar{31: 1})
+	assert.Equal(t, g, point.SerializeUncompressed())
+	decoded, err := g.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, point.Compress(), decoded.Compress())
+	compressed, err := g.Compress()
+	assert.Nil(t, err)
+	assert.Equal(t, point.Compress(), compressed)
+
+	// projective coordinates with z != 1
+	var doubled Point
+	doubled.GEProjDouble(&point)
+	uncompressed := doubled.SerializeUncompressed()
+	decoded, err = uncompressed.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, doubled.Compress(), decoded.Compress())
+
+	invalid := map[string]Uncompressed{}
+	invalid["zero value"] = Uncompressed{}
+	prefix := g
+	prefix[0] = 0x06
+	invalid["hybrid prefix"] = prefix
+	notOnCurve := g
+	notOnCurve[64] ^= 1
+	invalid["not on the curve"] = notOnCurve
+	// x = 1 + P is congruent to x = 1 of a valid point, but is not reduced
+	xNotReduced := Uncompressed(suppress(hex.DecodeString("04" + "0000000000000000000000000000000000000000000000000000000000000001" + "4218f20ae6c646b363db68605822fb14264ca8d2587fdd6fbc750d587e76a7ee")))
+	_, err = xNotReduced.Decode()
+	assert.Nil(t, err)
+	copy(xNotReduced[1:33], suppress(hex.DecodeString("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc30")))
+	invalid["x >= P"] = xNotReduced
+	for name, encoded := range invalid {
+		_, err := encoded.Decode()
+		assert.Equal(t, ErrorInvalidPoint, err, name)
+		_, err = encoded.Compress()
+		assert.Equal(t, ErrorInvalidPoint, err, name)
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	var point Point
+	point.GEPoint(Scalar{31: 3})
+	compressed := point.Compress()
+	uncompressed := point.SerializeUncompressed()
+	hybrid := uncompressed
+	hybrid[0] = 0x06 | uncompressed[64]&1
+	wrongParity := hybrid
+	wrongParity[0] ^= 1
+
+	for _, data := range [][]byte{compressed[:], uncompressed[:]} {
+		for _, parse := range []func([]byte) (*Point, error){ParsePoint, ParseHybridPoint} {
+			parsed, err := parse(data)
+			assert.Nil(t, err)
+			assert.Equal(t, compressed, parsed.Compress())
+		}
+	}
+	parsed, err := ParseHybridPoint(hybrid[:])
+	assert.Nil(t, err)
+	assert.Equal(t, compressed, parsed.Compress())
+
+	for _, data := range [][]byte{nil, {0x00}, compressed[:32], uncompressed[:64], hybrid[:], wrongParity[:]} {
+		_, err := ParsePoint(data)
+		assert.Equal(t, ErrorInvalidPoint, err)
+	}
+	for _, data := range [][]byte{nil, {0x00}, compressed[:32], uncompressed[:64], wrongParity[:]} {
+		_, err := ParseHybridPoint(data)
+		assert.Equal(t, ErrorInvalidPoint, err)
+	}
+}
+
+var (
+	// public keys of the BIP 32 test vectors (vector 1 m, m/0H and vector 2 m) and invalid ones from the failure vectors
+	fuzzCompressedCorpus = []string{
+		"0339a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2",
+		"035a784662a4a20a65bf6aab9ae98a6c068a81c52e4b032c0fb5400c706cfccc56",
+		"03cbcaa9c98c877a26977d00825c956a238e8dddfbd322cce4f74b0b5bd6ace4a7",
+		"020000000000000000000000000000000000000000000000000000000000000007",
+		"0439a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2",
+		"0139a36013301597daef41fbe593a02cc513d0b55527ec2df1050e2e8ff49c85c2",
+		"02fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc30",
+	}
+	// private keys of the BIP 32 test vectors (vector 1 m, m/0H and vector 2 m)
+	fuzzScalarCorpus = []string{
+		"e8f32e723decf4051aefac8e2c93c9c5b214313817cdb01a1494b917c8436b35",
+		"edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea",
+		"4b03d6fc340455b363f51020ad3ecca4f0850280cf436c70c727923f6db46c3e",
+	}
+)
+
+// FuzzUncompress compares Uncompress with math/big and btcutilecc.
+func FuzzUncompress(f *testing.F) {
+	for _, compressed := range fuzzCompressedCorpus {
+		f.Add(suppress(hex.DecodeString(compressed)))
+	}
+	sqrtExponent := new(big.Int).Rsh(new(big.Int).Add(pBig, big.NewInt(1)), 2)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) != 33 {
+			return
+		}
+		point, err := Compressed(data).Uncompress()
+
+		// y^2 = x^3 + 7
+		x := new(big.Int).SetBytes(data[1:])
+		ySquare := new(big.Int).Exp(x, big.NewInt(3), pBig)
+		ySquare.Add(ySquare, big.NewInt(7)).Mod(ySquare, pBig)
+		y := new(big.Int).Exp(ySquare, sqrtExponent, pBig)
+		valid := (data[0] == 2 || data[0] == 3) && x.Cmp(pBig) < 0 && new(big.Int).Exp(y, big.NewInt(2), pBig).Cmp(ySquare) == 0
+		if !valid {
+			assert.Equal(t, ErrorInvalidPoint, err)
+			return
+		}
+		assert.Nil(t, err)
+		if y.Bit(0) != uint(data[0]&1) {
+			y.Sub(pBig, y)
+		}
+		assert.True(t, btcutil.Secp256k1().IsOnCurve(x, y))
+		assert.Equal(t, feFromBig(x), point.x)
+		assert.Equal(t, feFromBig(y), point.y)
+		assert.Equal(t, Compressed(data), point.Compress())
+	})
+}
+
+// FuzzGEScalarMult compares GEPoint and GEScalarMult with btcutilecc.
+func FuzzGEScalarMult(f *testing.F) {
+	for _, a := range fuzzScalarCorpus {
+		for _, n := range fuzzScalarCorpus {
+			f.Add(suppress(hex.DecodeString(a)), suppress(hex.DecodeString(n)))
+		}
+	}
+	f.Add(make([]byte, 32), Order[:])
+	f.Add(Order[:], []byte{31: 1})
+	orderBig := new(big.Int).SetBytes(Order[:])
+	curve := btcutil.Secp256k1()
+	compress := func(x, y *big.Int) Compressed {
+		var result Compressed
+		result[0] = 2 | byte(y.Bit(0))
+		x.FillBytes(result[1:])
+		return result
+	}
+	f.Fuzz(func(t *testing.T, a []byte, n []byte) {
+		if len(a) != 32 || len(n) != 32 {
+			return
+		}
+		var aG, result Point
+		aG.GEPoint(Scalar(a))
+		result.GEScalarMult(&aG, Scalar(n))
+
+		aBig := new(big.Int).Mod(new(big.Int).SetBytes(a), orderBig)
+		if aBig.Sign() == 0 {
+			assert.Equal(t, 1, aG.IsInfinity())
+			assert.Equal(t, 1, result.IsInfinity())
+			return
+		}
+		x, y := curve.ScalarBaseMult(a)
+		assert.Equal(t, compress(x, y), aG.Compress())
+		product := new(big.Int).Mul(aBig, new(big.Int).SetBytes(n))
+		if product.Mod(product, orderBig).Sign() == 0 {
+			assert.Equal(t, 1, result.IsInfinity())
+			return
+		}
+		x, y = curve.ScalarMult(x, y, n)
+		assert.Equal(t, compress(x, y), result.Compress())
+	})
+}
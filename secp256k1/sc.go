@@ -3,6 +3,7 @@ package secp256k1
 // Reference: https://github.com/openssh/openssh-portable/blob/V_9_0_P1/sc25519.c
 import (
 	"crypto/subtle"
+	"encoding/binary"
 	"encoding/hex"
 	"math/bits"
 )
@@ -27,6 +28,15 @@ func SCAdd(a Scalar, b Scalar) Scalar {
 	return a
 }
 
+// SCSub returns (a - b) mod Order. Both a and b must be less than Order.
+// It runs in constant-time.
+func SCSub(a Scalar, b Scalar) Scalar {
+	neg := Order
+	inPlaceSubtract(&neg, b)
+	// if b = 0, neg = Order, which is reduced in SCAdd
+	return SCAdd(a, Scalar(neg))
+}
+
 // reduction mod Order
 // constant-time
 func scReduce(a *Scalar) {
@@ -135,3 +145,82 @@ func CompareUint32s(a [8]uint32, b [8]uint32) int {
 	}
 	return result
 }
+
+// scC = 2^256 - Order in little-endian 64-bit limbs, so that 2^256 = scC (mod Order).
+var scC = [3]uint64{0x402da1732fc9bebf, 0x4551231950b75fc4, 1}
+
+// scToLimbs converts a to little-endian 64-bit limbs.
+func scToLimbs(a Scalar) [4]uint64 {
+	var result [4]uint64
+	for i := 0; i < 4; i++ {
+		result[i] = binary.BigEndian.Uint64(a[24-8*i : 32-8*i])
+	}
+	return result
+}
+
+// scFold returns t' = t mod 2^256 + (t >> 256) * scC, which is congruent to t mod Order.
+// It runs in constant-time.
+func scFold(t [8]uint64) [8]uint64 {
+	var result [8]uint64
+	copy(result[:4], t[:4])
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < len(scC); j++ {
+			hi, lo := bits.Mul64(t[4+i], scC[j])
+			var c0, c1 uint64
+			result[i+j], c0 = bits.Add64(result[i+j], lo, 0)
+			result[i+j], c1 = bits.Add64(result[i+j], carry, 0)
+			carry = hi + c0 + c1
+		}
+		for k := i + len(scC); k < len(result); k++ {
+			result[k], carry = bits.Add64(result[k], carry, 0)
+		}
+	}
+	return result
+}
+
+// SCMul returns (a * b) mod Order.
+// It runs in constant-time.
+func SCMul(a Scalar, b Scalar) Scalar {
+	x := scToLimbs(a)
+	y := scToLimbs(b)
+	var t [8]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(x[i], y[j])
+			var c0, c1 uint64
+			t[i+j], c0 = bits.Add64(t[i+j], lo, 0)
+			t[i+j], c1 = bits.Add64(t[i+j], carry, 0)
+			carry = hi + c0 + c1
+		}
+		t[i+4] = carry
+	}
+	// t < 2^512 -> < 2^386 -> < 2^260 -> < 2^257 -> < 2^256 + 2^129 -> < 2^256
+	for i := 0; i < 5; i++ {
+		t = scFold(t)
+	}
+	var result Scalar
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(result[24-8*i:32-8*i], t[i])
+	}
+	scReduce(&result)
+	return result
+}
+
+// SCInv returns a^(-1) mod Order. It returns 0 if a = 0.
+// It runs in constant-time.
+func SCInv(a Scalar) Scalar {
+	// a^(Order-2) by square-and-multiply; the exponent is public
+	exponent := Order
+	exponent[31] -= 2
+	var result Scalar
+	result[31] = 1
+	for i := 0; i < 256; i++ {
+		result = SCMul(result, result)
+		if exponent[i/8]>>(7-i%8)&1 == 1 {
+			result = SCMul(result, a)
+		}
+	}
+	return result
+}
@@ -1,6 +1,11 @@
 package secp256k1
 
 import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,6 +19,18 @@ func TestSCAdd(t *testing.T) {
 	assert.Equal(t, b, SCAdd(a, a))
 }
 
+func TestSCSub(t *testing.T) {
+	var one, two Scalar
+	one[31] = 1
+	two[31] = 2
+	minusOne := Scalar(Order)
+	minusOne[31] -= 1
+	assert.Equal(t, one, SCSub(two, one))
+	assert.Equal(t, minusOne, SCSub(one, two))
+	assert.Equal(t, Scalar{}, SCSub(two, two))
+	assert.Equal(t, two, SCSub(two, Scalar{}))
+}
+
 func TestInplaceSubtract(t *testing.T) {
 	var a, b Scalar
 	a[31] = 4
@@ -21,3 +38,61 @@ func TestInplaceSubtract(t *testing.T) {
 	inPlaceSubtract((*[32]byte)(&a), b)
 	assert.Equal(t, a[0], byte(0xff))
 }
+
+func TestSCMul(t *testing.T) {
+	orderBig := new(big.Int).SetBytes(Order[:])
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		var a, b Scalar
+		rng.Read(a[:])
+		rng.Read(b[:])
+		if i == 0 {
+			// the largest 256-bit values
+			for j := range a {
+				a[j] = 0xff
+				b[j] = 0xff
+			}
+		}
+		expected := new(big.Int).Mul(new(big.Int).SetBytes(a[:]), new(big.Int).SetBytes(b[:]))
+		expected.Mod(expected, orderBig)
+		var expectedScalar Scalar
+		expected.FillBytes(expectedScalar[:])
+		assert.Equal(t, expectedScalar, SCMul(a, b))
+	}
+}
+
+func TestSCInv(t *testing.T) {
+	var one Scalar
+	one[31] = 1
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 10; i++ {
+		var a Scalar
+		rng.Read(a[:])
+		scReduce(&a)
+		assert.Equal(t, one, SCMul(a, SCInv(a)))
+	}
+	assert.Equal(t, Scalar{}, SCInv(Scalar{}))
+}
+
+func TestScalarRedacted(t *testing.T) {
+	var s Scalar
+	for i := range s {
+		s[i] = byte(0xa0 + i)
+	}
+	type holder struct {
+		Key Scalar
+	}
+	secretHex := hex.EncodeToString(s[:])
+	for _, formatted := range []string{
+		fmt.Sprint(s), fmt.Sprintf("%v %+v %#v %s %q %x %X %d", s, s, s, s, s, s, s, s),
+		fmt.Sprintf("%v %x", &s, []Scalar{s}), fmt.Sprintf("%v %+v %#v", holder{s}, &holder{s}, holder{s}),
+		s.String(), s.GoString(), s.LogValue().String(),
+	} {
+		assert.NotContains(t, formatted, secretHex)
+		assert.NotContains(t, strings.ToUpper(formatted), strings.ToUpper(secretHex))
+		assert.NotContains(t, formatted, "160 161 162")
+	}
+	assert.Equal(t, "secp256k1.Scalar(REDACTED)", fmt.Sprintf("%x", s))
+	// exporting is explicit
+	assert.Equal(t, secretHex, hex.EncodeToString(s[:]))
+}
@@ -0,0 +1,75 @@
+package secp256k1
+
+import "crypto/subtle"
+
+// SecretScalar is a Scalar which must be kept secret, such as a private key. Its zero value represents 0 mod Order.
+//
+// Unlike Scalar, a SecretScalar cannot be passed to functions taking a Scalar, including variable-time ones such as GEVartimePoint,
+// and cannot be compared with ==. All its methods run in constant-time.
+// NewSecretScalar converts a Scalar to a SecretScalar, and Declassify converts it back, stating that the value is made public.
+type SecretScalar struct {
+	_      [0]func() // forbids ==, which does not run in constant-time
+	scalar Scalar
+}
+
+// NewSecretScalar returns a SecretScalar with the value a. a is not reduced mod Order; see IsValid.
+func NewSecretScalar(a Scalar) SecretScalar {
+	return SecretScalar{scalar: a}
+}
+
+// Declassify returns the value of a as a Scalar, which is no longer protected by the type system.
+// It should be called only where the value leaves this type on purpose, for example when it is serialized.
+func (a SecretScalar) Declassify() Scalar {
+	return a.scalar
+}
+
+// Add returns (a + b) mod Order.
+func (a SecretScalar) Add(b SecretScalar) SecretScalar {
+	return SecretScalar{scalar: SCAdd(a.scalar, b.scalar)}
+}
+
+// Sub returns (a - b) mod Order. Both a and b must be less than Order.
+func (a SecretScalar) Sub(b SecretScalar) SecretScalar {
+	return SecretScalar{scalar: SCSub(a.scalar, b.scalar)}
+}
+
+// Mul returns (a * b) mod Order.
+func (a SecretScalar) Mul(b SecretScalar) SecretScalar {
+	return SecretScalar{scalar: SCMul(a.scalar, b.scalar)}
+}
+
+// Inv returns a^(-1) mod Order. It returns 0 if a = 0.
+func (a SecretScalar) Inv() SecretScalar {
+	return SecretScalar{scalar: SCInv(a.scalar)}
+}
+
+// IsValid returns 1 if a < Order, 0 otherwise.
+func (a SecretScalar) IsValid() int {
+	return SCIsValid(a.scalar)
+}
+
+// IsZero returns 1 if a = 0, 0 otherwise. a is not reduced mod Order, so it returns 0 if a = Order.
+func (a SecretScalar) IsZero() int {
+	var zeroScalar Scalar
+	return subtle.ConstantTimeCompare(a.scalar[:], zeroScalar[:])
+}
+
+// Equal returns 1 if a and b have the same value, 0 otherwise.
+func (a SecretScalar) Equal(b SecretScalar) int {
+	return subtle.ConstantTimeCompare(a.scalar[:], b.scalar[:])
+}
+
+// Clear overwrites a with zero.
+func (a *SecretScalar) Clear() {
+	clear(a.scalar[:])
+}
+
+// GESecretPoint computes n G where G is the base point. It runs in constant-time.
+func (p *Point) GESecretPoint(n SecretScalar) {
+	p.GEPoint(n.scalar)
+}
+
+// GESecretScalarMult computes n a. It runs in constant-time.
+func (p *ProjPoint) GESecretScalarMult(a *ProjPoint, n SecretScalar) {
+	p.GEScalarMult(a, n.scalar)
+}
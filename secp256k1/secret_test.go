@@ -0,0 +1,95 @@
+package secp256k1
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func randomScalar(rng *rand.Rand) Scalar {
+	var s Scalar
+	rng.Read(s[:])
+	scReduce(&s)
+	return s
+}
+
+func TestSecretScalarArithmetic(t *testing.T) {
+	rng := rand.New(rand.NewSource(48))
+	for i := 0; i < 100; i++ {
+		a := randomScalar(rng)
+		b := randomScalar(rng)
+		sa := NewSecretScalar(a)
+		sb := NewSecretScalar(b)
+		assert.Equal(t, a, sa.Declassify())
+		assert.Equal(t, SCAdd(a, b), sa.Add(sb).Declassify())
+		assert.Equal(t, SCSub(a, b), sa.Sub(sb).Declassify())
+		assert.Equal(t, SCMul(a, b), sa.Mul(sb).Declassify())
+		assert.Equal(t, SCInv(a), sa.Inv().Declassify())
+		assert.Equal(t, 1, sa.Equal(NewSecretScalar(a)))
+		assert.Equal(t, 0, sa.Equal(sb))
+	}
+}
+
+func TestSecretScalarPredicates(t *testing.T) {
+	var zero SecretScalar
+	assert.Equal(t, 1, zero.IsZero())
+	assert.Equal(t, 1, zero.IsValid())
+
+	order := NewSecretScalar(Order)
+	assert.Equal(t, 0, order.IsZero())
+	assert.Equal(t, 0, order.IsValid())
+
+	nMinus1 := Scalar(Order)
+	nMinus1[31]--
+	assert.Equal(t, 1, NewSecretScalar(nMinus1).IsValid())
+
+	s := NewSecretScalar(nMinus1)
+	s.Clear()
+	assert.Equal(t, 1, s.IsZero())
+}
+
+func TestGESecretPoint(t *testing.T) {
+	rng := rand.New(rand.NewSource(48))
+	for i := 0; i < 10; i++ {
+		n := randomScalar(rng)
+		var expected, actual Point
+		expected.GEPoint(n)
+		actual.GESecretPoint(NewSecretScalar(n))
+		assert.Equal(t, expected.Compress(), actual.Compress())
+
+		m := randomScalar(rng)
+		expected.GEScalarMult(&actual, m)
+		var product Point
+		product.GESecretScalarMult(&actual, NewSecretScalar(m))
+		assert.Equal(t, expected.Compress(), product.Compress())
+	}
+}
+
+func TestSecretScalarRedacted(t *testing.T) {
+	var raw Scalar
+	for i := range raw {
+		raw[i] = byte(0xa0 + i)
+	}
+	s := NewSecretScalar(raw)
+	type holder struct {
+		Key SecretScalar
+	}
+	secretHex := hex.EncodeToString(raw[:])
+	for _, formatted := range []string{
+		fmt.Sprint(s), fmt.Sprintf("%v %+v %#v %s %q %x %X %d", s, s, s, s, s, s, s, s),
+		fmt.Sprintf("%v %x", &s, []SecretScalar{s}), fmt.Sprintf("%v %+v %#v", holder{s}, &holder{s}, holder{s}),
+		s.String(), s.GoString(), s.LogValue().String(),
+	} {
+		assert.NotContains(t, formatted, secretHex)
+		assert.NotContains(t, strings.ToUpper(formatted), strings.ToUpper(secretHex))
+		assert.NotContains(t, formatted, "160 161 162")
+	}
+	assert.Equal(t, "secp256k1.SecretScalar(REDACTED)", fmt.Sprintf("%x", s))
+	// exporting is explicit
+	declassified := s.Declassify()
+	assert.Equal(t, secretHex, hex.EncodeToString(declassified[:]))
+}
@@ -0,0 +1,242 @@
+package secp256k1
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test vectors of Wycheproof (https://github.com/C2SP/wycheproof), vendored unchanged from testvectors_v1.
+// Their license is in testdata/wycheproof/LICENSE.
+//
+//go:embed testdata/wycheproof/*.json
+var wycheproofFiles embed.FS
+
+//go:embed testdata/points_test.json
+var pointVectorsFile []byte
+
+type wycheproofFile struct {
+	Schema        string            `json:"schema"`
+	NumberOfTests int               `json:"numberOfTests"`
+	TestGroups    []json.RawMessage `json:"testGroups"`
+}
+
+type wycheproofTest struct {
+	TcID    int      `json:"tcId"`
+	Comment string   `json:"comment"`
+	Flags   []string `json:"flags"`
+	Result  string   `json:"result"` // "valid", "invalid" or "acceptable"
+}
+
+type ecdhGroup struct {
+	Curve    string `json:"curve"`
+	Encoding string `json:"encoding"`
+	Tests    []struct {
+		wycheproofTest
+		Public  string `json:"public"` // X.509 SubjectPublicKeyInfo
+		Private string `json:"private"`
+		Shared  string `json:"shared"`
+	} `json:"tests"`
+}
+
+type ecdsaGroup struct {
+	PublicKey struct {
+		Curve        string `json:"curve"`
+		Uncompressed string `json:"uncompressed"`
+	} `json:"publicKey"`
+	Sha   string `json:"sha"`
+	Tests []struct {
+		wycheproofTest
+		Msg string `json:"msg"`
+		Sig string `json:"sig"` // DER
+	} `json:"tests"`
+}
+
+type pointVectors struct {
+	Points []struct {
+		Comment    string `json:"comment"`
+		Compressed string `json:"compressed"`
+		X          string `json:"x"`
+		Y          string `json:"y"`
+		Result     string `json:"result"`
+	} `json:"points"`
+	Multiplications []struct {
+		Comment  string `json:"comment"`
+		Scalar   string `json:"scalar"`
+		Point    string `json:"point"`
+		Result   string `json:"result"`
+		Infinity bool   `json:"infinity"`
+	} `json:"multiplications"`
+}
+
+// forEachWycheproofGroup calls f with every test group in testdata/wycheproof/name, which must have schema.
+// It returns the number of tests in the file.
+func forEachWycheproofGroup(t *testing.T, name string, schema string, f func(group json.RawMessage)) int {
+	var file wycheproofFile
+	assert.Nil(t, json.Unmarshal(suppress(wycheproofFiles.ReadFile("testdata/wycheproof/"+name)), &file), name)
+	assert.Equal(t, schema, file.Schema, name)
+	assert.NotEmpty(t, file.TestGroups, name)
+	for _, group := range file.TestGroups {
+		f(group)
+	}
+	return file.NumberOfTests
+}
+
+var (
+	oidECPublicKey = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidSecp256k1   = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// parsePublicKeyInfo parses an X.509 SubjectPublicKeyInfo of secp256k1 with a named curve.
+// Explicit curve parameters are not supported.
+func parsePublicKeyInfo(der []byte) (*Point, error) {
+	var info struct {
+		Algorithm struct {
+			Algorithm  asn1.ObjectIdentifier
+			Parameters asn1.RawValue
+		}
+		PublicKey asn1.BitString
+	}
+	if rest, err := asn1.Unmarshal(der, &info); err != nil || len(rest) != 0 || !info.Algorithm.Algorithm.Equal(oidECPublicKey) {
+		return nil, ErrorInvalidPoint
+	}
+	var curve asn1.ObjectIdentifier
+	if rest, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &curve); err != nil || len(rest) != 0 || !curve.Equal(oidSecp256k1) {
+		return nil, ErrorInvalidPoint
+	}
+	if info.PublicKey.BitLength != 8*len(info.PublicKey.Bytes) {
+		return nil, ErrorInvalidPoint
+	}
+	return ParsePoint(info.PublicKey.Bytes)
+}
+
+// scalarFromHex parses a big-endian integer with possible leading zeros.
+func scalarFromHex(s string) (Scalar, bool) {
+	b := suppress(hex.DecodeString(s))
+	for len(b) > 32 && b[0] == 0 {
+		b = b[1:]
+	}
+	var result Scalar
+	if len(b) > 32 {
+		return result, false
+	}
+	copy(result[32-len(b):], b)
+	return result, true
+}
+
+func TestWycheproofECDH(t *testing.T) {
+	count := 0
+	expected := forEachWycheproofGroup(t, "ecdh_secp256k1_test.json", "ecdh_test_schema_v1.json", func(rawGroup json.RawMessage) {
+		var group ecdhGroup
+		assert.Nil(t, json.Unmarshal(rawGroup, &group))
+		assert.Equal(t, "secp256k1", group.Curve)
+		assert.Equal(t, "asn", group.Encoding)
+		for _, test := range group.Tests {
+			count++
+			message := []any{test.TcID, test.Comment, test.Flags}
+			private, ok := scalarFromHex(test.Private)
+			assert.True(t, ok, message...)
+			public, err := parsePublicKeyInfo(suppress(hex.DecodeString(test.Public)))
+			if err != nil {
+				assert.NotEqual(t, "valid", test.Result, message...)
+				continue
+			}
+			var shared Point
+			shared.GEScalarMult(public, private)
+			compressed := shared.Compress()
+			if test.Result == "invalid" {
+				assert.NotEqual(t, test.Shared, hex.EncodeToString(compressed[1:]), message...)
+			} else {
+				assert.Equal(t, test.Shared, hex.EncodeToString(compressed[1:]), message...)
+			}
+		}
+	})
+	assert.Equal(t, expected, count)
+}
+
+func TestWycheproofECDSA(t *testing.T) {
+	count := 0
+	expected := forEachWycheproofGroup(t, "ecdsa_secp256k1_sha256_test.json", "ecdsa_verify_schema_v1.json", func(rawGroup json.RawMessage) {
+		var group ecdsaGroup
+		assert.Nil(t, json.Unmarshal(rawGroup, &group))
+		assert.Equal(t, "secp256k1", group.PublicKey.Curve)
+		assert.Equal(t, "SHA-256", group.Sha)
+		point, err := ParsePoint(suppress(hex.DecodeString(group.PublicKey.Uncompressed)))
+		assert.Nil(t, err)
+		publicKey := point.Compress()
+		for _, test := range group.Tests {
+			count++
+			message := []any{test.TcID, test.Comment, test.Flags}
+			hash := sha256.Sum256(suppress(hex.DecodeString(test.Msg)))
+			verified := false
+			if sig, err := ParseDERSignature(suppress(hex.DecodeString(test.Sig))); err == nil {
+				verified = ECDSAVerify(publicKey, hash, sig)
+				// DER encoding is strict, so a parsed signature is encoded back to the same bytes
+				assert.Equal(t, test.Sig, hex.EncodeToString(sig.DER()), message...)
+			}
+			switch test.Result {
+			case "valid":
+				assert.True(t, verified, message...)
+			case "invalid":
+				assert.False(t, verified, message...)
+			}
+		}
+	})
+	assert.Equal(t, expected, count)
+}
+
+func TestPointVectors(t *testing.T) {
+	var vectors pointVectors
+	assert.Nil(t, json.Unmarshal(pointVectorsFile, &vectors))
+	assert.NotEmpty(t, vectors.Points)
+	for _, test := range vectors.Points {
+		point, err := Compressed(suppress(hex.DecodeString(test.Compressed))).Uncompress()
+		if test.Result == "invalid" {
+			assert.Equal(t, ErrorInvalidPoint, err, test.Comment)
+			continue
+		}
+		assert.Nil(t, err, test.Comment)
+		x, y, compressed := point.x.Bytes(), point.y.Bytes(), point.Compress()
+		assert.Equal(t, test.X, hex.EncodeToString(x[:]), test.Comment)
+		assert.Equal(t, test.Y, hex.EncodeToString(y[:]), test.Comment)
+		assert.Equal(t, test.Compressed, hex.EncodeToString(compressed[:]), test.Comment)
+		uncompressed := point.SerializeUncompressed()
+		assert.Equal(t, "04"+test.X+test.Y, hex.EncodeToString(uncompressed[:]), test.Comment)
+		decoded, err := uncompressed.Compress()
+		assert.Nil(t, err, test.Comment)
+		assert.Equal(t, compressed, decoded, test.Comment)
+	}
+
+	assert.NotEmpty(t, vectors.Multiplications)
+	var g Point
+	g.GEPoint(Scalar{31: 1})
+	generator := g.Compress()
+	for _, test := range vectors.Multiplications {
+		k, ok := scalarFromHex(test.Scalar)
+		assert.True(t, ok, test.Comment)
+		base, err := Compressed(suppress(hex.DecodeString(test.Point))).Uncompress()
+		assert.Nil(t, err, test.Comment)
+		var results []Point
+		var result Point
+		result.GEScalarMult(base, k)
+		results = append(results, result)
+		if base.Compress() == generator {
+			result.GEPoint(k)
+			results = append(results, result)
+		}
+		for _, result := range results {
+			if test.Infinity {
+				assert.Equal(t, 1, result.IsInfinity(), test.Comment)
+				continue
+			}
+			assert.Equal(t, 0, result.IsInfinity(), test.Comment)
+			compressed := result.Compress()
+			assert.Equal(t, test.Result, hex.EncodeToString(compressed[:]), test.Comment)
+		}
+	}
+}
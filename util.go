@@ -6,6 +6,7 @@ import (
 	"crypto/sha512"
 	"encoding/binary"
 
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
 	//lint:ignore SA1019 we want to implement a bip32-oriented package, so using RIPEMD-160 is inevitable.
 	"golang.org/x/crypto/ripemd160"
 )
@@ -24,6 +25,23 @@ func hmacThing(chainCode [32]byte, keyElement [33]byte, childIdx uint32) [64]byt
 	return [64]byte(hash.Sum(nil))
 }
 
+// childHMACFunc computes I_L and I_R in child key derivation. It is always childHMAC,
+// except in tests which need invalid child keys (parse_{256}(I_L) >= n), since they are practically impossible to find.
+type childHMACFunc func(chainCode [32]byte, keyElement [33]byte, childIdx uint32) (ll [32]byte, lr [32]byte)
+
+// childHMAC computes I = hmacThing(chainCode, keyElement, childIdx) and splits it into I_L and I_R.
+func childHMAC(chainCode [32]byte, keyElement [33]byte, childIdx uint32) (ll [32]byte, lr [32]byte) {
+	l := hmacThing(chainCode, keyElement, childIdx)
+	return [32]byte(l[:32]), [32]byte(l[32:])
+}
+
+// nextChildIndex returns the index following childIdx without crossing the boundary between non-hardened and hardened indices.
+// The second return value is false if there is no such index.
+func nextChildIndex(childIdx uint32) (uint32, bool) {
+	next := childIdx + 1
+	return next, next != FirstHardenedChildIndex && next != 0
+}
+
 func hash160(a []byte) []byte {
 	hash := ripemd160.New()
 	intermediate := sha256.Sum256(a)
@@ -31,8 +49,16 @@ func hash160(a []byte) []byte {
 	return hash.Sum(nil)
 }
 
-func checksum(a []byte) [4]byte {
+func fingerprint(publicKey secp256k1.Compressed) [4]byte {
+	return [4]byte(hash160(publicKey[:]))
+}
+
+func doubleSHA256(a []byte) [32]byte {
 	intermediate := sha256.Sum256(a)
-	hash := sha256.Sum256(intermediate[:])
+	return sha256.Sum256(intermediate[:])
+}
+
+func checksum(a []byte) [4]byte {
+	hash := doubleSHA256(a)
 	return [4]byte(hash[:4])
 }
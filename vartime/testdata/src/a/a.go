@@ -0,0 +1,69 @@
+package a
+
+type Scalar [32]byte
+
+type PrivateKey struct {
+	key Scalar
+}
+
+func scIsValid(s Scalar) int {
+	return int(s[0] & 1)
+}
+
+func scalarVartimeInv(s Scalar) Scalar {
+	return s
+}
+
+func pointVartimeAdd(x, y []byte) []byte {
+	return x
+}
+
+func (p *PrivateKey) Bytes() []byte {
+	return pointVartimeAdd(p.key[:], nil) // want `call to variable-time function pointVartimeAdd in Bytes, which handles secret values`
+}
+
+func public(x []byte) []byte {
+	return pointVartimeAdd(x, x)
+}
+
+func invert(key *PrivateKey) Scalar {
+	return scalarVartimeInv(key.key) // want `call to variable-time function scalarVartimeInv in invert, which handles secret values`
+}
+
+func field() Scalar {
+	var s Scalar
+	return scalarVartimeInv(s) // want `secret value of type a.Scalar passed to variable-time function scalarVartimeInv`
+}
+
+func branch(s Scalar) int {
+	var zero Scalar
+	if s == zero { // want `branch on secret value of type a.Scalar`
+		return 0
+	}
+	if scIsValid(s) != 1 {
+		return 1
+	}
+	for i := 0; i < len(s); i++ {
+	}
+	switch s[0] { // want `branch on secret value of type a.Scalar`
+	case 0:
+		return 2
+	}
+	//vartime:public s is public here
+	if s == zero {
+		return 3
+	}
+	return 4
+}
+
+// verify works on public values only.
+//
+//vartime:public
+func verify(s Scalar) bool {
+	var zero Scalar
+	return s != zero && len(scalarVartimeInv(s)) == 32
+}
+
+func vartimeHelper(s Scalar) Scalar {
+	return scalarVartimeInv(s)
+}
@@ -0,0 +1,235 @@
+// Package vartime provides an analyzer which finds variable-time operations on secret values.
+//
+// This module distinguishes constant-time functions (such as feInv and GEProjPoint) from variable-time ones
+// (such as feVartimeInv and GEVartimePoint) only by their names. The analyzer reports:
+//   - calls to functions whose names contain "vartime" (case-insensitively) from functions which handle secrets,
+//     namely methods of secret holder types (such as PrivateKey) and functions with a receiver or a parameter of a secret or secret holder type
+//   - calls to such functions with an argument of a secret type, wherever they are
+//   - if, for and switch statements whose conditions depend on values of secret types (such as Scalar)
+//
+// Types are matched by their names regardless of their packages; see the flags -types and -holders.
+// Results of function calls in conditions are considered public unless they are of secret types,
+// so checks with constant-time functions such as `if SCIsValid(k) != 1` are not reported;
+// the analyzer does not track secret values through other types.
+// Functions whose names contain "vartime" and test files are not checked.
+// A statement or a whole function working on values which are public although their types are secret types,
+// such as signatures in ECDSA verification, can be exempted with the directive
+//
+//	//vartime:public
+//
+// in the doc comment of the function, or on the line of the statement or the line before it.
+//
+// The analyzer can be run with go vet:
+//
+//	go build -o /tmp/vartimecheck ./cmd/vartimecheck
+//	go vet -vettool=/tmp/vartimecheck ./...
+package vartime
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const directive = "//vartime:public"
+
+// Analyzer reports variable-time operations on secret values.
+var Analyzer = &analysis.Analyzer{
+	Name: "vartime",
+	Doc:  "report variable-time function calls and branches on secret values\n\nSee https://pkg.go.dev/github.com/koba-e964/bip32-typesafe/vartime for details.",
+	URL:  "https://pkg.go.dev/github.com/koba-e964/bip32-typesafe/vartime",
+	Run:  run,
+}
+
+var (
+	secretTypes       = "Scalar,SecretScalar"
+	secretHolderTypes = "PrivateKey"
+)
+
+func init() {
+	Analyzer.Flags.StringVar(&secretTypes, "types", secretTypes, "comma-separated names of types whose values are secret")
+	Analyzer.Flags.StringVar(&secretHolderTypes, "holders", secretHolderTypes, "comma-separated names of types which hold secret values")
+}
+
+type checker struct {
+	pass    *analysis.Pass
+	secrets map[string]bool
+	holders map[string]bool
+	// lines with the directive, keyed by file name
+	exemptLines map[string]map[int]bool
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	c := checker{
+		pass:        pass,
+		secrets:     nameSet(secretTypes),
+		holders:     nameSet(secretHolderTypes),
+		exemptLines: map[string]map[int]bool{},
+	}
+	for _, file := range pass.Files {
+		fileName := pass.Fset.File(file.Pos()).Name()
+		if strings.HasSuffix(fileName, "_test.go") {
+			continue
+		}
+		lines := map[int]bool{}
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				if isDirective(comment.Text) {
+					lines[pass.Fset.Position(comment.Pos()).Line] = true
+				}
+			}
+		}
+		c.exemptLines[fileName] = lines
+
+		for _, decl := range file.Decls {
+			if decl, ok := decl.(*ast.FuncDecl); ok && decl.Body != nil {
+				c.checkFunc(decl)
+			}
+		}
+	}
+	return nil, nil
+}
+
+func nameSet(names string) map[string]bool {
+	result := map[string]bool{}
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			result[name] = true
+		}
+	}
+	return result
+}
+
+func isDirective(text string) bool {
+	return text == directive || strings.HasPrefix(text, directive+" ")
+}
+
+func isVartimeName(name string) bool {
+	return strings.Contains(strings.ToLower(name), "vartime")
+}
+
+func (c *checker) checkFunc(decl *ast.FuncDecl) {
+	if isVartimeName(decl.Name.Name) {
+		return
+	}
+	if decl.Doc != nil {
+		for _, comment := range decl.Doc.List {
+			if isDirective(comment.Text) {
+				return
+			}
+		}
+	}
+	handlesSecrets := false
+	var fields []*ast.Field
+	if decl.Recv != nil {
+		fields = append(fields, decl.Recv.List...)
+	}
+	fields = append(fields, decl.Type.Params.List...)
+	for _, field := range fields {
+		t := c.pass.TypesInfo.TypeOf(field.Type)
+		if c.isSecret(t) || c.isHolder(t) {
+			handlesSecrets = true
+		}
+	}
+
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpr:
+			c.checkCall(node, decl.Name.Name, handlesSecrets)
+		case *ast.IfStmt:
+			c.checkCondition(node, node.Cond)
+		case *ast.ForStmt:
+			c.checkCondition(node, node.Cond)
+		case *ast.SwitchStmt:
+			if node.Tag != nil {
+				c.checkCondition(node, node.Tag)
+			} else {
+				for _, clause := range node.Body.List {
+					for _, expr := range clause.(*ast.CaseClause).List {
+						c.checkCondition(clause, expr)
+					}
+				}
+			}
+		}
+		return true
+	})
+}
+
+func (c *checker) checkCall(call *ast.CallExpr, caller string, handlesSecrets bool) {
+	callee, ok := typeutil.Callee(c.pass.TypesInfo, call).(*types.Func)
+	if !ok || !isVartimeName(callee.Name()) || c.isExempt(call) {
+		return
+	}
+	if handlesSecrets {
+		c.pass.Reportf(call.Pos(), "call to variable-time function %s in %s, which handles secret values", callee.Name(), caller)
+		return
+	}
+	for _, arg := range call.Args {
+		if t := c.pass.TypesInfo.TypeOf(arg); c.isSecret(t) {
+			c.pass.Reportf(arg.Pos(), "secret value of type %s passed to variable-time function %s", t, callee.Name())
+			return
+		}
+	}
+}
+
+// checkCondition reports if cond depends on a secret value.
+func (c *checker) checkCondition(stmt ast.Node, cond ast.Expr) {
+	if cond == nil || c.isExempt(stmt) {
+		return
+	}
+	var secret ast.Expr
+	ast.Inspect(cond, func(node ast.Node) bool {
+		if secret != nil {
+			return false
+		}
+		if call, ok := node.(*ast.CallExpr); ok && !c.isSecret(c.pass.TypesInfo.TypeOf(call)) {
+			// results of calls which are not secret values, such as lengths and results of constant-time checks, are considered public
+			return false
+		}
+		if expr, ok := node.(ast.Expr); ok && c.isSecret(c.pass.TypesInfo.TypeOf(expr)) {
+			secret = expr
+			return false
+		}
+		return true
+	})
+	if secret != nil {
+		c.pass.Reportf(secret.Pos(), "branch on secret value of type %s", c.pass.TypesInfo.TypeOf(secret))
+	}
+}
+
+func (c *checker) isExempt(node ast.Node) bool {
+	position := c.pass.Fset.Position(node.Pos())
+	lines := c.exemptLines[position.Filename]
+	return lines[position.Line] || lines[position.Line-1]
+}
+
+// typeName returns the name of t, looking through pointers, slices and arrays, or "" if t is not a named type.
+func typeName(t types.Type) string {
+	for {
+		switch u := t.(type) {
+		case *types.Pointer:
+			t = u.Elem()
+		case *types.Slice:
+			t = u.Elem()
+		case *types.Array:
+			t = u.Elem()
+		case *types.Named:
+			return u.Obj().Name()
+		case *types.Alias:
+			t = types.Unalias(u)
+		default:
+			return ""
+		}
+	}
+}
+
+func (c *checker) isSecret(t types.Type) bool {
+	return t != nil && c.secrets[typeName(t)]
+}
+
+func (c *checker) isHolder(t types.Type) bool {
+	return t != nil && c.holders[typeName(t)]
+}
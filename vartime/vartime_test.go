@@ -0,0 +1,12 @@
+package vartime_test
+
+import (
+	"testing"
+
+	"github.com/koba-e964/bip32-typesafe/vartime"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), vartime.Analyzer, "a")
+}
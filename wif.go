@@ -0,0 +1,153 @@
+package bip32
+
+import (
+	"crypto/subtle"
+
+	"github.com/koba-e964/base58-go"
+	"github.com/koba-e964/bip32-typesafe/secp256k1"
+)
+
+const (
+	wifVersion        = 0x80
+	testnetWIFVersion = 0xef
+	// the suffix of keys whose public keys are compressed
+	wifCompressedSuffix = 0x01
+)
+
+// WIFKey is a non-extended private key in the wallet import format (WIF),
+// which Bitcoin Core's importprivkey and dumpprivkey use.
+type WIFKey struct {
+	privateKey secp256k1.SecretScalar
+	compressed bool
+	testnet    bool
+}
+
+// PrivateKey returns the private key of secp256k1 in this WIFKey.
+// The result is declassified: it is a plain Scalar, which can be passed to variable-time functions and compared with ==.
+// Use SecretPrivateKey to keep the key in constant-time operations.
+func (w *WIFKey) PrivateKey() secp256k1.Scalar {
+	return w.privateKey.Declassify()
+}
+
+// SecretPrivateKey returns the private key of secp256k1 in this WIFKey as a SecretScalar,
+// which allows only constant-time operations.
+func (w *WIFKey) SecretPrivateKey() secp256k1.SecretScalar {
+	return w.privateKey
+}
+
+// PublicKey returns the compressed public key of this WIFKey, regardless of IsCompressed.
+func (w *WIFKey) PublicKey() secp256k1.Compressed {
+	var pubKey secp256k1.Point
+	pubKey.GESecretPoint(w.privateKey)
+	return pubKey.Compress()
+}
+
+// IsCompressed returns true if the public key of this WIFKey is used in the compressed form in scripts.
+func (w *WIFKey) IsCompressed() bool {
+	return w.compressed
+}
+
+// IsTestnet returns true if this WIFKey is for testnet (version byte 0xef), false if it is for mainnet (0x80).
+func (w *WIFKey) IsTestnet() bool {
+	return w.testnet
+}
+
+// WIF returns the WIF representation of this WIFKey.
+func (w *WIFKey) WIF() string {
+	version := byte(wifVersion)
+	if w.testnet {
+		version = testnetWIFVersion
+	}
+	privateKey := w.privateKey.Declassify()
+	data := append([]byte{version}, privateKey[:]...)
+	if w.compressed {
+		data = append(data, wifCompressedSuffix)
+	}
+	// the version byte is non-zero, so the length of the encoding only depends on the length of data
+	return base58CheckEncode(data, wifLength(w.compressed))
+}
+
+func wifLength(compressed bool) int {
+	if compressed {
+		return 52
+	}
+	return 51
+}
+
+// WIFKey returns the private key in this PrivateKey as a non-extended key.
+// The network is mainnet or testnet according to the version of this PrivateKey.
+// If compressed is true, the key is marked to be used with the compressed public key, as Bitcoin Core does by default.
+func (p *PrivateKey) WIFKey(compressed bool) *WIFKey {
+	return &WIFKey{
+		privateKey: p.privateKey,
+		compressed: compressed,
+		testnet:    p.version == [4]byte(testnetPrivateKeyVersion),
+	}
+}
+
+// WIF returns the WIF representation of the private key in this PrivateKey. It is equivalent to p.WIFKey(compressed).WIF().
+func (p *PrivateKey) WIF(compressed bool) string {
+	return p.WIFKey(compressed).WIF()
+}
+
+// ParseWIF decodes a private key in the wallet import format. The following errors may be returned:
+//   - ErrorInvalidWIF: if the length, the version byte or the compression flag is invalid
+//   - ErrorChecksumMismatch: if the checksum is wrong
+//   - ErrorPrivateKeyNotInRange: if the private key is not in 1 <= p <= n-1
+func ParseWIF(encoded string) (*WIFKey, error) {
+	var compressed bool
+	switch len(encoded) {
+	case wifLength(false):
+	case wifLength(true):
+		compressed = true
+	default:
+		return nil, ErrorInvalidWIF
+	}
+	dataLength := 33
+	if compressed {
+		dataLength = 34
+	}
+	data, err := base58CheckDecode(encoded, dataLength, ErrorInvalidWIF)
+	if err != nil {
+		return nil, err
+	}
+	w := WIFKey{compressed: compressed}
+	switch data[0] {
+	case wifVersion:
+	case testnetWIFVersion:
+		w.testnet = true
+	default:
+		return nil, ErrorInvalidWIF
+	}
+	if compressed && data[33] != wifCompressedSuffix {
+		return nil, ErrorInvalidWIF
+	}
+	w.privateKey = secp256k1.NewSecretScalar(secp256k1.Scalar(data[1:33]))
+	if isPrivateKeyInRange(w.privateKey) != 1 {
+		return nil, ErrorPrivateKeyNotInRange
+	}
+	return &w, nil
+}
+
+// base58CheckEncode encodes data followed by its checksum into a base58 string with length resultLength.
+// It runs in constant-time.
+func base58CheckEncode(data []byte, resultLength int) string {
+	chksum := checksum(data)
+	return base58.Encode(append(append([]byte{}, data...), chksum[:]...), resultLength)
+}
+
+// base58CheckDecode decodes a base58 string of dataLength bytes followed by a checksum and returns the data.
+// It returns ErrorChecksumMismatch if the checksum is wrong, and malformed if encoded is not a base58 encoding of that length.
+// It runs in constant-time.
+func base58CheckDecode(encoded string, dataLength int, malformed error) ([]byte, error) {
+	data := make([]byte, dataLength+4)
+	// rejects invalid characters and encodings that overflow data
+	if base58DecodeCanonical(encoded, data) != 1 {
+		return nil, malformed
+	}
+	chksum := checksum(data[:dataLength])
+	if subtle.ConstantTimeCompare(data[dataLength:], chksum[:]) != 1 {
+		return nil, ErrorChecksumMismatch
+	}
+	return data[:dataLength], nil
+}